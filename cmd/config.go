@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// flagconfig holds the settings given on the command line, which take
+// precedence over those in the configuration file.
+var flagconfig config.Config
+
+func addconfigflags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(
+		&flagconfig.ChromaStyle, "style", "s", "", "Chroma style to use",
+	)
+	cmd.Flags().StringVarP(
+		&flagconfig.Theme, "theme", "t", "", "Theme directory",
+	)
+	cmd.Flags().StringVarP(
+		&flagconfig.BaseURL, "baseurl", "u", "",
+		"Absolute URL the site is hosted at",
+	)
+	cmd.Flags().StringVar(
+		&flagconfig.Title, "title", "", "Title of the site",
+	)
+	cmd.Flags().StringVar(
+		&flagconfig.DateFormat, "dateformat", "",
+		"Go time layout used to format dates",
+	)
+	cmd.Flags().StringVar(
+		&flagconfig.Cache, "cache", "",
+		"Directory of the render cache (default none)",
+	)
+	cmd.Flags().StringVar(
+		&flagconfig.PathPrefix, "pathprefix", "",
+		"URL path the site is served under (default the root)",
+	)
+	cmd.Flags().BoolVar(
+		&flagconfig.Gitignore, "gitignore", false,
+		"Also leave out files matched by .gitignore files in the source",
+	)
+	cmd.Flags().IntVarP(
+		&flagconfig.Workers, "jobs", "j", 0,
+		"Number of pages to process at once (default one per CPU)",
+	)
+}
+
+// overrides are the settings that take precedence over the configuration file.
+// A theme given as a positional argument is used unless the flag is set.
+func overrides(theme string) *config.Config {
+	return (&config.Config{Theme: theme}).Override(&flagconfig)
+}
+
+// loadconfig reads the configuration file in src and applies the overrides.
+func loadconfig(src, theme string) (*config.Config, error) {
+	cfg, err := config.Load(src)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load config: %w", err)
+	}
+	return withoverrides(cfg, theme)
+}
+
+// withoverrides applies the overrides to cfg, which must then name a theme.
+func withoverrides(cfg *config.Config, theme string) (*config.Config, error) {
+	cfg = cfg.Override(overrides(theme))
+	if cfg.Theme == "" {
+		return nil, fmt.Errorf(
+			"no theme given and none in %s", config.File,
+		)
+	}
+	return cfg, nil
+}
@@ -2,41 +2,125 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 
 	"github.com/spf13/cobra"
 	"github.com/hylodoc/hyloblog-ssg/internal/ast/area"
 	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/areainfo"
+	"github.com/hylodoc/hyloblog-ssg/internal/config"
 )
 
 var genCmd = &cobra.Command{
 	Use:   "gen [source] [target] [theme]",
 	Short: "Generate a site from Markdown files and directories",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if len(args) < 3 {
+		if len(args) < 2 {
 			return fmt.Errorf(
-				"must provide source, target and theme directories",
+				"must provide source and target directories",
 			)
 		}
-		src, target, theme := args[0], args[1], args[2]
+		src, target, theme := args[0], args[1], optionalarg(args, 2)
 
-		blog, err := area.ParseArea(src, chromastyle)
-		if err != nil {
-			return fmt.Errorf("cannot parse: %w", err)
+		if !genwatch {
+			_, err := gen(src, target, theme)
+			return err
+		}
+		if genref != "" {
+			return fmt.Errorf("cannot watch a ref")
 		}
-		if err := blog.GenerateSite(
-			target, theme, areainfo.PurposeStaticServe,
-		); err != nil {
-			return fmt.Errorf("cannot generate: %w", err)
+		cfg, err := gen(src, target, theme)
+		if err != nil {
+			log.Printf("build failed: %v\n", err)
+			cfg = area.WatchConfig(src, overrides(theme))
 		}
-		return nil
+		return area.Watch(
+			src, []string{target}, cfg,
+			func() (*config.Config, error) {
+				return gen(src, target, theme)
+			},
+		)
 	},
 }
 
-var chromastyle string
+func gen(src, target, theme string) (*config.Config, error) {
+	cfg, blog, err := parsesource(src, target, theme)
+	if err != nil {
+		return nil, err
+	}
+	if err := blog.Inject(cfg.Pages()); err != nil {
+		return nil, fmt.Errorf("cannot inject: %w", err)
+	}
+	if err := blog.GenerateSite(
+		target, cfg, areainfo.PurposeStaticServe,
+	); err != nil {
+		return nil, fmt.Errorf("cannot generate: %w", err)
+	}
+	return cfg, nil
+}
+
+// genwatch keeps gen running, regenerating whenever the source or theme change.
+var genwatch bool
+
+// genref is the branch, tag or commit to build from instead of the working tree.
+var genref string
+
+// parsesource parses the site in src, leaving out target, or, if a ref is
+// given, in the tree at that ref of the git repository at src.
+func parsesource(
+	src, target, theme string,
+) (*config.Config, *area.Area, error) {
+	if genref != "" {
+		return parseref(src, genref, theme)
+	}
+	cfg, err := loadconfig(src, theme)
+	if err != nil {
+		return nil, nil, err
+	}
+	blog, err := area.ParseArea(src, cfg, false, target)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot parse: %w", err)
+	}
+	return cfg, blog, nil
+}
+
+func parseref(
+	repo, ref, theme string,
+) (*config.Config, *area.Area, error) {
+	r, err := area.OpenRef(repo, ref)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot open ref: %w", err)
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot load config: %w", err)
+	}
+	if cfg, err = withoverrides(cfg, theme); err != nil {
+		return nil, nil, err
+	}
+	blog, err := r.Parse(cfg, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot parse: %w", err)
+	}
+	return cfg, blog, nil
+}
+
+func optionalarg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
 
 func init() {
-	rootCmd.AddCommand(genCmd)
-	rootCmd.Flags().StringVarP(
-		&chromastyle, "style", "s", "based", "Chroma style to use",
+	addconfigflags(genCmd)
+	genCmd.Flags().StringVar(
+		&genref, "ref", "",
+		"Build from this branch, tag or commit of the git repository "+
+			"at source rather than its working tree",
+	)
+	genCmd.Flags().BoolVarP(
+		&genwatch, "watch", "w", false,
+		"Regenerate whenever files under source or the theme change",
 	)
+	rootCmd.AddCommand(genCmd)
 }
@@ -1,51 +1,155 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/hylodoc/hyloblog-ssg/internal/ast/area"
+	"github.com/hylodoc/hyloblog-ssg/internal/middleware"
 )
 
 var serveCmd = &cobra.Command{
 	Use:   "serve [source] [theme]",
 	Short: "Serve a blog from Markdown files and directories",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if len(args) < 2 {
-			return fmt.Errorf(
-				"must provide source and theme directories",
-			)
+		if len(args) < 1 {
+			return fmt.Errorf("must provide source directory")
 		}
-		src, theme := args[0], args[1]
+		src, theme := args[0], optionalarg(args, 1)
 
-		h, err := choosehandler(src, theme, livereload)
+		h, err := choosehandler(
+			src, theme, livereload, branches, drafts,
+		)
 		if err != nil {
 			return fmt.Errorf("cannot choose handler: %w", err)
 		}
+		if d, ok := h.(destroyer); ok {
+			defer d.Destroy()
+		}
+		wrapped, err := withmiddleware(h)
+		if err != nil {
+			return err
+		}
 		s := &http.Server{
-			Addr:           fmt.Sprintf(":%d", port),
-			Handler:        h,
-			ReadTimeout:    10 * time.Second,
-			WriteTimeout:   10 * time.Second,
-			MaxHeaderBytes: 1 << 20,
+			Addr:              fmt.Sprintf(":%d", port),
+			Handler:           wrapped,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       10 * time.Second,
+			WriteTimeout:      10 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    1 << 20,
 		}
-		log.Printf("listening on %s...", s.Addr)
-		return s.ListenAndServe()
+		if c, ok := h.(streamcloser); ok {
+			s.RegisterOnShutdown(c.CloseStreams)
+		}
+		return listen(s)
 	},
 }
 
-func choosehandler(src, theme string, livereload bool) (http.Handler, error) {
+// shutdownTimeout bounds how long the requests in flight are waited for once
+// the server is asked to stop.
+const shutdownTimeout = 30 * time.Second
+
+type destroyer interface {
+	Destroy() error
+}
+
+// A streamcloser ends the responses that would otherwise hold up a shutdown.
+type streamcloser interface {
+	CloseStreams()
+}
+
+// listen serves until interrupted and then stops accepting connections,
+// waiting for the requests in flight to finish.
+func listen(s *http.Server) error {
+	if (tlscert == "") != (tlskey == "") {
+		return fmt.Errorf("must provide both a TLS certificate and key")
+	}
+	ctx, stop := signal.NotifyContext(
+		context.Background(), os.Interrupt, syscall.SIGTERM,
+	)
+	defer stop()
+	errc := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %s...", s.Addr)
+		if tlscert != "" {
+			errc <- s.ListenAndServeTLS(tlscert, tlskey)
+		} else {
+			errc <- s.ListenAndServe()
+		}
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	log.Println("shutting down...")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		return fmt.Errorf("cannot shut down: %w", err)
+	}
+	return nil
+}
+
+// withmiddleware logs and compresses the responses of h, asking for
+// credentials first if basic auth is configured.
+func withmiddleware(h http.Handler) (http.Handler, error) {
+	h = middleware.Compress(h)
+	if auth == "" {
+		auth = os.Getenv(authEnv)
+	}
+	if auth != "" {
+		user, password, ok := strings.Cut(auth, ":")
+		if !ok {
+			return nil, fmt.Errorf("auth must be of the form user:password")
+		}
+		h = middleware.BasicAuth(h, user, password)
+	}
+	return middleware.Log(h), nil
+}
+
+func choosehandler(
+	src, theme string, livereload, branches, drafts bool,
+) (http.Handler, error) {
+	if branches {
+		if livereload {
+			return nil, fmt.Errorf(
+				"cannot live reload branch previews",
+			)
+		}
+		// each branch is configured by its own file
+		h, err := area.PreviewHandler(src, overrides(theme), drafts)
+		if err != nil {
+			return nil, fmt.Errorf("cannot make previews: %w", err)
+		}
+		return h, nil
+	}
+	cfg, err := loadconfig(src, theme)
+	if err != nil {
+		return nil, err
+	}
 	if livereload {
-		return area.CreateLiveHandler(src, theme, chromastyle), nil
+		return area.CreateLiveHandler(
+			src, overrides(theme), drafts,
+		), nil
 	}
-	blog, err := area.ParseArea(src, chromastyle)
+	blog, err := area.ParseArea(src, cfg, drafts)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse area: %w", err)
 	}
-	h, err := blog.Handler(theme)
+	if err := blog.Inject(cfg.Pages()); err != nil {
+		return nil, fmt.Errorf("cannot inject: %w", err)
+	}
+	h, err := blog.Handler(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("cannot make http handler: %w", err)
 	}
@@ -55,8 +159,17 @@ func choosehandler(src, theme string, livereload bool) (http.Handler, error) {
 var (
 	port       int
 	livereload bool
+	branches   bool
+	drafts     bool
+
+	tlscert, tlskey string
+	auth            string
 )
 
+// authEnv is read for the basic auth credentials when --auth is not given, so
+// that they need not appear in the command line.
+const authEnv = "HYLOBLOG_AUTH"
+
 func init() {
 	serveCmd.Flags().IntVarP(
 		&port, "port", "p", 8000, "Port to serve on",
@@ -64,8 +177,24 @@ func init() {
 	serveCmd.Flags().BoolVarP(
 		&livereload, "livereload", "D", false, "Enable live reloading",
 	)
-	serveCmd.Flags().StringVarP(
-		&chromastyle, "style", "s", "based", "Chroma style to use",
+	serveCmd.Flags().BoolVar(
+		&branches, "branches", false,
+		"Preview every local branch under /_preview/<branch>",
+	)
+	serveCmd.Flags().BoolVar(
+		&drafts, "drafts", false, "Include pages marked as drafts",
+	)
+	serveCmd.Flags().StringVar(
+		&tlscert, "tls-cert", "", "Serve HTTPS with this certificate file",
+	)
+	serveCmd.Flags().StringVar(
+		&tlskey, "tls-key", "", "Private key file for --tls-cert",
+	)
+	serveCmd.Flags().StringVar(
+		&auth, "auth", "",
+		"Require HTTP basic auth with these credentials, as user:password "+
+			"(defaults to $"+authEnv+")",
 	)
+	addconfigflags(serveCmd)
 	rootCmd.AddCommand(serveCmd)
 }
@@ -8,10 +8,10 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/gorilla/mux"
@@ -20,12 +20,17 @@ import (
 	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/readdir"
 	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/sitefile"
 	"github.com/hylodoc/hyloblog-ssg/internal/ast/page"
+	"github.com/hylodoc/hyloblog-ssg/internal/cache"
+	"github.com/hylodoc/hyloblog-ssg/internal/config"
+	"github.com/hylodoc/hyloblog-ssg/internal/hash"
 	"github.com/hylodoc/hyloblog-ssg/internal/theme"
+	"github.com/hylodoc/hyloblog-ssg/internal/work"
 )
 
 const (
-	indexFile  = "index.md"
-	ignoreFile = ".hyloblogignore"
+	indexFile     = "index.md"
+	ignoreFile    = ".hyloblogignore"
+	gitignoreFile = ".gitignore"
 )
 
 type Area struct {
@@ -34,7 +39,15 @@ type Area struct {
 	pages      map[string]page.Page
 	otherfiles map[string]readdir.File
 
+	// sources are the paths of the files in the area's directory that
+	// affect the generated output
+	sources []string
+
 	hash string
+
+	// cache holds the rendered pages, and is pruned of those that generating
+	// did not use
+	cache *cache.Cache
 }
 
 func newarea(prefix string) *Area {
@@ -43,7 +56,9 @@ func newarea(prefix string) *Area {
 		[]Area{},
 		map[string]page.Page{},
 		map[string]readdir.File{},
+		[]string{},
 		"",
+		nil,
 	}
 }
 
@@ -54,12 +69,25 @@ func (A *Area) Hash() (string, error) {
 	return A.hash, nil
 }
 
-func ParseArea(dir, chromastyle string) (*Area, error) {
-	A, err := parse(dir, dir, areainfo.NewParseInfo(chromastyle))
+// ParseArea parses the source in dir. The render cache and the directories in
+// skip, such as a target inside dir, are left out.
+func ParseArea(
+	dir string, cfg *config.Config, drafts bool, skip ...string,
+) (*Area, error) {
+	w := newwalk(cfg, skip)
+	c := opencache(cfg)
+	A, err := parseall(
+		dir,
+		areainfo.NewParseInfo(
+			readdir.DirSource(dir), cfg.ChromaStyle, drafts,
+		).WithCache(c),
+		w, cfg.Workers,
+	)
 	if err != nil {
 		return nil, err
 	}
-	h, err := gethash(dir)
+	A.cache = c
+	h, err := gethash(dir, A, w)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get hash: %w", err)
 	}
@@ -67,15 +95,15 @@ func ParseArea(dir, chromastyle string) (*Area, error) {
 	return A, nil
 }
 
-func gethash(dir string) (string, error) {
+func gethash(dir string, A *Area, w *walk) (string, error) {
 	gitdir, err := getgitdir(dir)
 	if err != nil {
 		if errors.Is(err, errNotGitDir) {
-			return dirhash(dir)
+			return dirhash(dir, A, w)
 		}
 		return "", fmt.Errorf("error getting git dir: %w", err)
 	}
-	return getgithash(gitdir)
+	return getgithash(gitdir, w)
 }
 
 var errNotGitDir = errors.New("not git dir")
@@ -103,31 +131,45 @@ func isgitdir(gitdir string) (bool, error) {
 	return stat.IsDir(), nil
 }
 
-func dirhash(dir string) (string, error) {
-	cmd := exec.Command(
-		"sh", "-c",
-		fmt.Sprintf("tar cf - %s | %s", dir, gethashcmd()),
-	)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf(
-			"run error: %w, stderr: %s", err, stderr.String(),
-		)
+func dirhash(dir string, A *Area, w *walk) (string, error) {
+	var paths []string
+	for _, path := range A.allsources() {
+		if !w.drafts[abspath(path)] {
+			paths = append(paths, path)
+		}
+	}
+	cfgpath := filepath.Join(dir, config.File)
+	if exists, err := fileexists(cfgpath); err != nil {
+		return "", fmt.Errorf("cannot check config: %w", err)
+	} else if exists {
+		paths = append(paths, cfgpath)
 	}
-	return stdout.String(), nil
+	return hash.Files(dir, paths)
 }
 
-func gethashcmd() string {
-	if runtime.GOOS == "darwin" {
-		return "shasum -a 256"
+func (A *Area) allsources() []string {
+	sources := append([]string{}, A.sources...)
+	for _, a := range A.subareas {
+		sources = append(sources, a.allsources()...)
 	}
-	return "sha256sum"
+	return sources
 }
 
-func getgithash(gitdir string) (string, error) {
-	repo, err := git.PlainOpen(gitdir)
+func fileexists(path string) (bool, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// getgithash identifies the source by its HEAD commit. If the working tree has
+// uncommitted changes outside the directories w skips these are folded in, so
+// that editing a file changes the hash before it is committed.
+func getgithash(gitdir string, w *walk) (string, error) {
+	repo, err := git.PlainOpen(filepath.Dir(gitdir))
 	if err != nil {
 		return "", fmt.Errorf("cannot open repo: %w", err)
 	}
@@ -140,11 +182,174 @@ func getgithash(gitdir string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("cannot get commit: %w", err)
 	}
-	return commit.Hash.String(), nil
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("cannot get worktree: %w", err)
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return "", fmt.Errorf("cannot get status: %w", err)
+	}
+	paths := changedpaths(filepath.Dir(gitdir), status, w)
+	if len(paths) == 0 {
+		return commit.Hash.String(), nil
+	}
+	digest, err := statusdigest(filepath.Dir(gitdir), status, paths)
+	if err != nil {
+		return "", fmt.Errorf("cannot digest changes: %w", err)
+	}
+	return hash.Combine(commit.Hash.String(), digest), nil
+}
+
+// changedpaths returns the sorted paths of the files in status that differ
+// from HEAD, except those in directories w skips and drafts left out.
+func changedpaths(root string, status git.Status, w *walk) []string {
+	var paths []string
+	for path, s := range status {
+		if s.Staging == git.Unmodified && s.Worktree == git.Unmodified {
+			continue
+		}
+		abs := filepath.Join(root, filepath.FromSlash(path))
+		if w.skips(abs) || w.drafts[abspath(abs)] {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// statusdigest hashes the paths, status codes and current contents of the
+// files at paths in status.
+func statusdigest(
+	root string, status git.Status, paths []string,
+) (string, error) {
+	var parts []string
+	for _, path := range paths {
+		s := status[path]
+		sum, err := hash.File(filepath.Join(root, filepath.FromSlash(path)))
+		if err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("cannot hash %q: %w", path, err)
+		}
+		parts = append(
+			parts,
+			fmt.Sprintf("%c%c %s %s", s.Staging, s.Worktree, path, sum),
+		)
+	}
+	return hash.Combine(parts...), nil
+}
+
+// opencache opens the render cache configured in cfg. The site is built
+// without a cache if none is configured or it cannot be opened.
+func opencache(cfg *config.Config) *cache.Cache {
+	if cfg.Cache == "" {
+		return nil
+	}
+	c, err := cache.Open(cfg.Cache)
+	if err != nil {
+		log.Printf("warning: no cache: %v\n", err)
+		return nil
+	}
+	return c
+}
+
+// ignorefiles are the names of the files whose patterns leave out files of the
+// source, in ascending order of precedence.
+func ignorefiles(cfg *config.Config) []string {
+	if cfg.Gitignore {
+		return []string{gitignoreFile, ignoreFile}
+	}
+	return []string{ignoreFile}
+}
+
+// A walk holds what is gathered and what stays fixed while walking the source.
+type walk struct {
+	ignorefiles []string
+
+	// skip holds the absolute paths of directories left out
+	skip []string
+	jobs []*pagejob
+
+	// drafts holds the absolute paths of the drafts left out
+	drafts map[string]bool
+}
+
+func newwalk(cfg *config.Config, skip []string) *walk {
+	w := &walk{ignorefiles: ignorefiles(cfg), drafts: map[string]bool{}}
+	if cfg.Cache != "" {
+		skip = append([]string{cfg.Cache}, skip...)
+	}
+	for _, dir := range skip {
+		if abs, err := filepath.Abs(dir); err == nil {
+			w.skip = append(w.skip, abs)
+		}
+	}
+	return w
 }
 
-func parse(dir, parent string, info *areainfo.ParseInfo) (*Area, error) {
-	info, err := info.Descend(dir, ignoreFile)
+// skips reports whether path is in, or is, a directory left out.
+func (w *walk) skips(path string) bool {
+	abs := abspath(path)
+	for _, s := range w.skip {
+		if abs == s ||
+			strings.HasPrefix(abs, s+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// abspath returns path made absolute, or path itself if that fails.
+func abspath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// A pagejob is a page found while walking the source, to be parsed into pages.
+type pagejob struct {
+	path  string
+	info  *areainfo.ParseInfo
+	pages map[string]page.Page
+	page  page.Page
+}
+
+// parseall parses the area at dir. The directories are walked first, and the
+// pages found are then parsed on up to workers goroutines.
+func parseall(
+	dir string, info *areainfo.ParseInfo, w *walk, workers int,
+) (*Area, error) {
+	A, err := parse(dir, dir, info, w)
+	if err != nil {
+		return nil, err
+	}
+	jobs := w.jobs
+	if err := work.Do(len(jobs), workers, func(i int) error {
+		j := jobs[i]
+		pg, err := parsepage(j.path, j.info)
+		if err != nil {
+			return &ParseError{j.path, err}
+		}
+		j.page = pg
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	for _, j := range jobs {
+		if j.page.IsDraft() && !j.info.Drafts() {
+			w.drafts[abspath(j.path)] = true
+			continue
+		}
+		j.pages[filepath.Base(j.path)] = j.page
+	}
+	return A, nil
+}
+
+func parse(
+	dir, parent string, info *areainfo.ParseInfo, w *walk,
+) (*Area, error) {
+	info, err := info.Descend(dir, w.ignorefiles...)
 	if err != nil {
 		return nil, fmt.Errorf("cannot descend info: %w", err)
 	}
@@ -153,20 +358,28 @@ func parse(dir, parent string, info *areainfo.ParseInfo) (*Area, error) {
 		return nil, fmt.Errorf("cannot get prefix: %w", err)
 	}
 	A := newarea(prefix)
-	areadir, err := readdir.ReadDir(dir)
+	for _, name := range w.ignorefiles {
+		ignpath := filepath.Join(dir, name)
+		if exists, err := info.Source().Exists(ignpath); err != nil {
+			return nil, fmt.Errorf("cannot check ignore file: %w", err)
+		} else if exists {
+			A.sources = append(A.sources, ignpath)
+		}
+	}
+	areadir, err := info.Source().ReadDir(dir)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read dir: %w", err)
 	}
 	for _, d := range areadir.Directories() {
 		path := d.Path()
 		base := filepath.Base(path)
-		if info.ShouldIgnore(base) {
+		if info.ShouldIgnore(path, true) {
 			continue
 		}
-		if base == ".git" {
+		if base == ".git" || w.skips(path) {
 			continue
 		}
-		a, err := parse(path, dir, info)
+		a, err := parse(path, dir, info, w)
 		if err != nil {
 			return nil, fmt.Errorf(
 				"cannot parse subarea %q: %w", path, err,
@@ -177,23 +390,20 @@ func parse(dir, parent string, info *areainfo.ParseInfo) (*Area, error) {
 	for _, f := range areadir.Files() {
 		path := f.Path()
 		base := filepath.Base(path)
-		if info.ShouldIgnore(base) {
+		if info.ShouldIgnore(path, false) {
 			continue
 		}
 		if filepath.Ext(base) != ".md" {
 			if includefile(base) {
-				A.otherfiles[base] = readdir.NewFile(path)
+				A.otherfiles[base] = f
+				A.sources = append(A.sources, path)
 			}
 			continue
 		}
-		page, err := parsepage(path, info)
-		if err != nil {
-			return nil, fmt.Errorf(
-				"cannot parse page %q: %w",
-				path, err,
-			)
-		}
-		A.pages[base] = page
+		A.sources = append(A.sources, path)
+		w.jobs = append(
+			w.jobs, &pagejob{path: path, info: info, pages: A.pages},
+		)
 	}
 	return A, nil
 }
@@ -209,11 +419,29 @@ func getprefix(dir, parent string) (string, error) {
 	return prefix, nil
 }
 
+// A ParseError reports the page at Path failing to parse.
+type ParseError struct {
+	Path string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("cannot parse page %q: %v", e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
 func parsepage(path string, info *areainfo.ParseInfo) (page.Page, error) {
-	if gitdir, ok := info.GitDir(); ok {
-		return page.ParsePageGit(path, gitdir, info.ChromaStyle())
+	buf, err := info.Source().ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file: %w", err)
+	}
+	if git, ok := info.GitIndex(); ok {
+		return page.ParsePageGit(
+			path, buf, git, info.ChromaStyle(), info.Cache(),
+		)
 	}
-	return page.ParsePage(path, info.ChromaStyle())
+	return page.ParsePage(buf, info.ChromaStyle(), info.Cache())
 }
 
 func includefile(name string) bool {
@@ -263,16 +491,61 @@ func (A *Area) inject(url string, pg sitefile.CustomPage) error {
 }
 
 func (A *Area) GenerateSite(
-	target string, themedir string, p areainfo.Purpose,
+	target string, cfg *config.Config, p areainfo.Purpose,
 ) error {
-	thm, err := theme.ParseTheme(themedir)
+	g, err := newgeninfo(target, cfg, p)
+	if err != nil {
+		return err
+	}
+	return A.generate(sitedir(target, g), g)
+}
+
+func newgeninfo(
+	target string, cfg *config.Config, p areainfo.Purpose,
+) (*areainfo.GenInfo, error) {
+	thm, err := theme.ParseTheme(cfg.Theme)
 	if err != nil {
-		return fmt.Errorf("cannot parse theme: %w", err)
+		return nil, fmt.Errorf("cannot parse theme: %w", err)
 	}
-	return A.generate(target, areainfo.NewGenInfo(thm, target, p))
+	return areainfo.NewGenInfo(thm, target, p).
+		WithBaseURL(cfg.BaseURL).
+		WithHeadFoot(cfg.Head, cfg.Foot).
+		WithSiteTitle(cfg.Title).
+		WithDateFormat(cfg.DateFormat).
+		WithRobots(cfg.Robots).
+		WithWorkers(cfg.Workers).
+		WithPathPrefix(cfg.PathPrefix), nil
 }
 
+// sitedir is the directory under target that the site mounted at the path
+// prefix of g is generated into.
+func sitedir(target string, g *areainfo.GenInfo) string {
+	return filepath.Join(target, filepath.FromSlash(g.PathPrefix()))
+}
+
+// generate writes the site into target. The directories are made first, and
+// the files are then written on up to g.Workers() goroutines.
 func (A *Area) generate(target string, g *areainfo.GenInfo) error {
+	var tasks []func() error
+	if err := A.gentasks(target, g, &tasks); err != nil {
+		return err
+	}
+	if err := work.Do(len(tasks), g.Workers(), func(i int) error {
+		return tasks[i]()
+	}); err != nil {
+		return err
+	}
+	if err := A.cache.Prune(); err != nil {
+		log.Printf("warning: cannot prune cache: %v\n", err)
+	}
+	return nil
+}
+
+// gentasks makes the directory of the area and those of its subareas, adding
+// the tasks that write their files to tasks in a deterministic order.
+func (A *Area) gentasks(
+	target string, g *areainfo.GenInfo, tasks *[]func() error,
+) error {
 	if index, ok := A.pages[indexFile]; ok {
 		g = g.WithNewIndex(index)
 	}
@@ -281,52 +554,107 @@ func (A *Area) generate(target string, g *areainfo.GenInfo) error {
 		return fmt.Errorf("cannot make dir: %w", err)
 	}
 	for _, a := range A.subareas {
-		if err := a.generate(dir, g); err != nil {
+		if err := a.gentasks(dir, g, tasks); err != nil {
 			return fmt.Errorf(
 				"cannot generate subarea %q: %w",
 				filepath.Join(dir, a.prefix), err,
 			)
 		}
 	}
-	for name, page := range A.pages {
-		if err := A.generatepagefiles(name, dir, page, g); err != nil {
+	for _, name := range sortedkeys(A.pages) {
+		name, page := name, A.pages[name]
+		*tasks = append(*tasks, func() error {
+			err := A.generatepagefiles(name, dir, page, g)
+			if err != nil {
+				return fmt.Errorf(
+					"cannot generate page: %q: %w",
+					filepath.Join(dir, name), err,
+				)
+			}
+			return nil
+		})
+	}
+	for _, name := range sortedkeys(A.otherfiles) {
+		name, f := name, A.otherfiles[name]
+		*tasks = append(*tasks, func() error {
+			path := filepath.Join(dir, name)
+			if err := fcopy(f, path); err != nil {
+				return fmt.Errorf("cannot copy %q: %w", path, err)
+			}
+			return nil
+		})
+	}
+	*tasks = append(*tasks, func() error {
+		if err := A.generatefeeds(dir, g); err != nil {
 			return fmt.Errorf(
-				"cannot generate page: %q: %w", name, err,
+				"cannot generate feeds in %q: %w", dir, err,
 			)
 		}
-	}
-	for name, f := range A.otherfiles {
-		if err := fcopy(f.Path(), filepath.Join(dir, name)); err != nil {
-			return fmt.Errorf("cannot copy %q: %w", name, err)
-		}
+		return nil
+	})
+	if A.isroot() {
+		*tasks = append(*tasks, func() error {
+			if err := A.generatetags(dir, g); err != nil {
+				return fmt.Errorf("cannot generate tags: %w", err)
+			}
+			return nil
+		}, func() error {
+			if err := A.generatesitemap(dir, g); err != nil {
+				return fmt.Errorf(
+					"cannot generate sitemap: %w", err,
+				)
+			}
+			return nil
+		}, func() error {
+			if err := generateerrorpages(dir, g); err != nil {
+				return fmt.Errorf(
+					"cannot generate error pages: %w", err,
+				)
+			}
+			return nil
+		})
 	}
 	return nil
 }
 
+func sortedkeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (A *Area) generatepagefiles(
 	name, dir string, page page.Page, g *areainfo.GenInfo,
 ) error {
+	files, links, err := historyfiles(page, name, dir, g)
+	if err != nil {
+		return fmt.Errorf("history paths: %w", err)
+	}
+	if len(files) > 0 {
+		g = g.WithHistoryLink(links.History)
+	}
 	if err := A.generatepage(name, dir, page, g); err != nil {
 		return fmt.Errorf("page: %w", err)
 	}
+	if err := generatehistory(page, name, dir, g); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
 	if !g.Binding() || !page.IsPost() {
 		// we only generate emails when binding posts
 		return nil
 	}
-	f_html, err := os.Create(genemailhtmlpath(name, dir))
-	if err != nil {
-		return fmt.Errorf("html email file: %w", err)
-	}
-	defer f_html.Close()
-	if err := page.GenerateEmailHtml(f_html, g); err != nil {
+	if err := writefile(
+		genemailhtmlpath(name, dir),
+		func(w io.Writer) error { return page.GenerateEmailHtml(w, g) },
+	); err != nil {
 		return fmt.Errorf("generate html email: %w", err)
 	}
-	f_text, err := os.Create(genemailtextpath(name, dir))
-	if err != nil {
-		return fmt.Errorf("text email file: %w", err)
-	}
-	defer f_text.Close()
-	if err := page.GenerateEmailText(f_text); err != nil {
+	if err := writefile(
+		genemailtextpath(name, dir), page.GenerateEmailText,
+	); err != nil {
 		return fmt.Errorf("generate text email: %w", err)
 	}
 	return nil
@@ -335,18 +663,15 @@ func (A *Area) generatepagefiles(
 func (A *Area) generatepage(
 	name, dir string, page page.Page, g *areainfo.GenInfo,
 ) error {
-	f, err := os.Create(genpagepath(name, dir))
-	if err != nil {
-		return fmt.Errorf("cannot create file: %w", err)
-	}
-	defer f.Close()
-	if name == indexFile {
-		return page.GenerateIndex(f, A.getposts(dir, g), g)
-	}
-	if index, ok := g.GetIndex(); ok {
-		return page.Generate(f, g, index)
-	}
-	return page.GenerateWithoutIndex(f, g)
+	return writefile(genpagepath(name, dir), func(w io.Writer) error {
+		if name == indexFile {
+			return page.GenerateIndex(w, A.getposts(dir, g), g)
+		}
+		if index, ok := g.GetIndex(); ok {
+			return page.Generate(w, g, index)
+		}
+		return page.GenerateWithoutIndex(w, g)
+	})
 }
 
 func genpagepath(name, dir string) string {
@@ -374,7 +699,7 @@ func (A *Area) getposts(dir string, g *areainfo.GenInfo) []page.Post {
 			a.getposts(filepath.Join(dir, a.prefix), g)...,
 		)
 	}
-	for name := range A.pages {
+	for _, name := range sortedkeys(A.pages) {
 		if name == indexFile {
 			continue
 		}
@@ -389,32 +714,54 @@ func (A *Area) getposts(dir string, g *areainfo.GenInfo) []page.Post {
 	return posts
 }
 
-func fcopy(srcpath, dstpath string) error {
-	src, err := os.Open(srcpath)
+func fcopy(f readdir.File, dstpath string) error {
+	src, err := f.Open()
 	if err != nil {
 		return fmt.Errorf("cannot open source: %w", err)
 	}
 	defer src.Close()
-	dst, err := os.Create(dstpath)
-	if err != nil {
-		return fmt.Errorf("cannot create destination: %w", err)
-	}
-	defer dst.Close()
-	if _, err := io.Copy(dst, src); err != nil {
-		return fmt.Errorf("io copy error: %w", err)
+	return writefile(dstpath, func(w io.Writer) error {
+		if _, err := io.Copy(w, src); err != nil {
+			return fmt.Errorf("io copy error: %w", err)
+		}
+		return nil
+	})
+}
+
+// writefile writes what write produces to path. A file that already has that
+// content is left untouched, so that only changed outputs are rewritten.
+func writefile(path string, write func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	if old, err := os.ReadFile(path); err == nil &&
+		bytes.Equal(old, buf.Bytes()) {
+		return nil
 	}
-	if err := dst.Sync(); err != nil {
-		return fmt.Errorf("flush error: %w", err)
+	if err := os.WriteFile(path, buf.Bytes(), 0666); err != nil {
+		return fmt.Errorf("cannot write file: %w", err)
 	}
 	return nil
 }
 
 type Handler struct {
-	h         http.Handler
-	targetdir string
+	h          http.Handler
+	targetdir  string
+	routes     map[string]string
+	errorpages map[int]string
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("panic serving %s: %v\n", r.URL, p)
+			serveerrorpage(
+				w, h.errorpages[http.StatusInternalServerError],
+				http.StatusInternalServerError,
+			)
+		}
+	}()
 	h.h.ServeHTTP(w, r)
 }
 
@@ -422,38 +769,47 @@ func (h *Handler) Destroy() error {
 	return os.RemoveAll(h.targetdir)
 }
 
-func (A *Area) Handler(themedir string) (*Handler, error) {
+func (A *Area) Handler(cfg *config.Config) (*Handler, error) {
 	target, err := os.MkdirTemp("", "")
 	if err != nil {
 		return nil, fmt.Errorf("cannot make tempdir: %w", err)
 	}
-	purpose := areainfo.PurposeDynamicServe
-	if err := A.GenerateSite(target, themedir, purpose); err != nil {
+	g, err := newgeninfo(target, cfg, areainfo.PurposeDynamicServe)
+	if err != nil {
+		return nil, err
+	}
+	dir := sitedir(target, g)
+	if err := A.generate(dir, g); err != nil {
+		os.RemoveAll(target)
 		return nil, fmt.Errorf("cannot generate site: %w", err)
 	}
 	r := mux.NewRouter()
 	r.StrictSlash(true)
-	thm, err := theme.ParseTheme(themedir)
-	if err != nil {
-		return nil, fmt.Errorf("cannot parse theme: %w", err)
+	h := &Handler{r, target, map[string]string{}, errorpages(dir, g)}
+	r.NotFoundHandler = errorpagehandler(
+		h.errorpages[http.StatusNotFound], http.StatusNotFound,
+	)
+	return h, A.registerhandlers(dir, g, h.route(r))
+}
+
+// route returns a function that serves the file at path under url on r and
+// records it in the routes of h.
+func (h *Handler) route(r *mux.Router) func(url, path string) {
+	return func(url, path string) {
+		h.routes[url] = path
+		r.HandleFunc(url, filehandler(path))
 	}
-	return &Handler{r, target},
-		A.registerhandlers(
-			target,
-			areainfo.NewGenInfo(thm, target, purpose),
-			r,
-		)
 }
 
 func (A *Area) registerhandlers(
-	target string, g *areainfo.GenInfo, mux *mux.Router,
+	target string, g *areainfo.GenInfo, handle func(url, path string),
 ) error {
 	if index, ok := A.pages[indexFile]; ok {
 		g = g.WithNewIndex(index)
 	}
 	dir := filepath.Join(target, A.prefix)
 	for _, a := range A.subareas {
-		if err := a.registerhandlers(dir, g, mux); err != nil {
+		if err := a.registerhandlers(dir, g, handle); err != nil {
 			return fmt.Errorf(
 				"cannot register subarea %q: %w",
 				filepath.Join(dir, a.prefix), err,
@@ -467,7 +823,16 @@ func (A *Area) registerhandlers(
 				"cannot make path for %q: %w", name, err,
 			)
 		}
-		mux.HandleFunc(path, filehandler(genpagepath(name, dir)))
+		handle(path, genpagepath(name, dir))
+		files, _, err := historyfiles(A.pages[name], name, dir, g)
+		if err != nil {
+			return fmt.Errorf(
+				"cannot get history of %q: %w", name, err,
+			)
+		}
+		for _, hf := range files {
+			handle(hf.url, hf.path)
+		}
 	}
 	for name := range A.otherfiles {
 		path, err := filehostpath(name, dir, g.Root())
@@ -476,7 +841,29 @@ func (A *Area) registerhandlers(
 				"cannot make path for %q: %w", name, err,
 			)
 		}
-		mux.HandleFunc(path, filehandler(filepath.Join(dir, name)))
+		handle(path, filepath.Join(dir, name))
+	}
+	feedpaths, err := A.feedhostpaths(dir, g)
+	if err != nil {
+		return fmt.Errorf("cannot get feed paths: %w", err)
+	}
+	for url, path := range feedpaths {
+		handle(url, path)
+	}
+	if A.isroot() {
+		tagfiles, err := A.tagfiles(dir, g)
+		if err != nil {
+			return fmt.Errorf("cannot get tag files: %w", err)
+		}
+		for _, tf := range tagfiles {
+			handle(tf.url, tf.path)
+		}
+		for url, path := range sitemaphostpaths(dir, g) {
+			handle(url, path)
+		}
+		for _, ef := range errorfiles(dir, g) {
+			handle(ef.url, ef.path)
+		}
 	}
 	return nil
 }
@@ -506,33 +893,73 @@ func filehostpath(name, dir, rootdir string) (string, error) {
 	return filepath.Join("/", rel), nil
 }
 
+// filehandler serves the file at path with an ETag derived from its content.
+// Pages and feeds are revalidated on every use, while images, whose contents
+// seldom change, may be cached for a while.
 func filehandler(path string) http.HandlerFunc {
+	etag := sync.OnceValue(func() string {
+		sum, err := hash.File(path)
+		if err != nil {
+			log.Printf("warning: cannot hash %q: %v\n", path, err)
+			return ""
+		}
+		return `"` + sum[:32] + `"`
+	})
+	cachecontrol := "public, no-cache"
+	if includefile(path) {
+		cachecontrol = "public, max-age=3600"
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		log.Println(r.URL, "->", path)
+		if tag := etag(); tag != "" {
+			w.Header().Set("ETag", tag)
+		}
+		w.Header().Set("Cache-Control", cachecontrol)
 		http.ServeFile(w, r, path)
 	}
 }
 
 func (A *Area) GenerateWithBindings(
-	target, themedir, head, foot string,
+	target string, cfg *config.Config,
 ) (map[string]sitefile.Resource, error) {
-	thm, err := theme.ParseTheme(themedir)
+	g, err := newgeninfo(target, cfg, areainfo.PurposeBind)
 	if err != nil {
-		return nil, fmt.Errorf("cannot parse theme: %w", err)
+		return nil, err
 	}
-	g := areainfo.NewGenInfo(
-		thm, target, areainfo.PurposeBind,
-	).WithHeadFoot(head, foot)
-	if err := A.generate(target, g); err != nil {
+	dir := sitedir(target, g)
+	if err := A.generate(dir, g); err != nil {
 		return nil, fmt.Errorf("cannot generate: %w", err)
 	}
 	bindings := map[string]sitefile.Resource{}
-	if err := A.handlebindings(target, g, bindings); err != nil {
+	if err := A.handlebindings(dir, g, bindings); err != nil {
 		return nil, fmt.Errorf("cannot get bindings: %w", err)
 	}
+	if err := describebindings(bindings, g); err != nil {
+		return nil, fmt.Errorf("cannot describe bindings: %w", err)
+	}
 	return bindings, nil
 }
 
+// describebindings adds the metadata of the generated files to the bindings in m,
+// giving each the canonical URL under the base URL.
+func describebindings(m map[string]sitefile.Resource, g *areainfo.GenInfo) error {
+	urls := sortedkeys(m)
+	described := make([]sitefile.Resource, len(urls))
+	if err := work.Do(len(urls), g.Workers(), func(i int) error {
+		rsc, err := sitefile.Describe(m[urls[i]], g.BaseURL()+urls[i])
+		if err != nil {
+			return fmt.Errorf("%q: %w", urls[i], err)
+		}
+		described[i] = rsc
+		return nil
+	}); err != nil {
+		return err
+	}
+	for i, url := range urls {
+		m[url] = described[i]
+	}
+	return nil
+}
+
 func (A *Area) handlebindings(
 	target string, g *areainfo.GenInfo, m map[string]sitefile.Resource,
 ) error {
@@ -556,11 +983,20 @@ func (A *Area) handlebindings(
 				"cannot make path for %q: %w", name, err,
 			)
 		}
-		file, err := pagefile(pg, name, dir, g)
+		file, err := pagefile(pg, name, dir, path, g)
 		if err != nil {
 			return fmt.Errorf("cannot get page file: %w", err)
 		}
 		m[path] = file
+		files, _, err := historyfiles(pg, name, dir, g)
+		if err != nil {
+			return fmt.Errorf(
+				"cannot get history of %q: %w", name, err,
+			)
+		}
+		for _, hf := range files {
+			m[hf.url] = sitefile.NewNonPostResource(hf.path)
+		}
 	}
 	for name := range A.otherfiles {
 		path, err := filehostpath(name, dir, g.Root())
@@ -571,48 +1007,43 @@ func (A *Area) handlebindings(
 		}
 		m[path] = sitefile.NewNonPostResource(filepath.Join(dir, name))
 	}
+	feedpaths, err := A.feedhostpaths(dir, g)
+	if err != nil {
+		return fmt.Errorf("cannot get feed paths: %w", err)
+	}
+	for url, path := range feedpaths {
+		m[url] = sitefile.NewNonPostResource(path)
+	}
+	if A.isroot() {
+		tagfiles, err := A.tagfiles(dir, g)
+		if err != nil {
+			return fmt.Errorf("cannot get tag files: %w", err)
+		}
+		for _, tf := range tagfiles {
+			m[tf.url] = sitefile.NewNonPostResource(tf.path)
+		}
+		for url, path := range sitemaphostpaths(dir, g) {
+			m[url] = sitefile.NewNonPostResource(path)
+		}
+		for _, ef := range errorfiles(dir, g) {
+			m[ef.url] = sitefile.NewNonPostResource(ef.path)
+		}
+	}
 	return nil
 }
 
 func pagefile(
-	pg page.Page, name, dir string, g *areainfo.GenInfo,
+	pg page.Page, name, dir, link string, g *areainfo.GenInfo,
 ) (sitefile.Resource, error) {
 	pagepath := genpagepath(name, dir)
 	if name == indexFile {
 		return sitefile.NewNonPostResource(pagepath), nil
 	}
+	index, _ := g.GetIndex()
 	return pg.ToResource(
 		pagepath,
 		genemailhtmlpath(name, dir),
 		genemailtextpath(name, dir),
+		g, index, link,
 	)
 }
-
-type LiveHandler struct {
-	src, theme, chromastyle string
-}
-
-func CreateLiveHandler(src, theme, chromastyle string) *LiveHandler {
-	return &LiveHandler{src, theme, chromastyle}
-}
-
-func (lh *LiveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h, err := lh.genhandler()
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer h.Destroy()
-	h.ServeHTTP(w, r)
-}
-
-func (lh *LiveHandler) genhandler() (*Handler, error) {
-	blog, err := ParseArea(lh.src, lh.chromastyle)
-	if err != nil {
-		return nil, fmt.Errorf("cannot parse: %w", err)
-	}
-	h, err := blog.Handler(lh.theme)
-	if err != nil {
-		return nil, fmt.Errorf("cannot get handler: %w", err)
-	}
-	return h, nil
-}
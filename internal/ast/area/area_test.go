@@ -0,0 +1,356 @@
+package area
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/areainfo"
+	"github.com/hylodoc/hyloblog-ssg/internal/config"
+)
+
+func TestHash(t *testing.T) {
+	if err := testHash(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testHash() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := writefiles(dir, map[string]string{
+		"index.md":        "# index",
+		"post.md":         "# post",
+		"draft.md":        "---\ndraft: true\n---\n# draft",
+		ignoreFile:        "scratch.md\n",
+		"sub/logo.png":    "png",
+		"sub/another.md":  "# another",
+		"sub/ignored.txt": "not an output",
+	}); err != nil {
+		return err
+	}
+	orig, err := areahash(dir)
+	if err != nil {
+		return err
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "post.md"), old, old); err != nil {
+		return err
+	}
+	if err := writefiles(dir, map[string]string{
+		"scratch.md": "# scratch",
+		"draft.md":   "---\ndraft: true\n---\n# edited draft",
+	}); err != nil {
+		return err
+	}
+	if h, err := areahash(dir); err != nil {
+		return err
+	} else if h != orig {
+		return fmt.Errorf("mtime, ignored file or draft changed hash")
+	}
+	if err := writefiles(dir, map[string]string{
+		"post.md": "# edited",
+	}); err != nil {
+		return err
+	}
+	if h, err := areahash(dir); err != nil {
+		return err
+	} else if h == orig {
+		return fmt.Errorf("edit did not change hash")
+	}
+	return nil
+}
+
+func TestGitignore(t *testing.T) {
+	if err := testGitignore(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testGitignore() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := writefiles(dir, map[string]string{
+		"post.md":     "# post",
+		"scratch.md":  "# scratch",
+		gitignoreFile: "scratch.md\n",
+	}); err != nil {
+		return err
+	}
+	for _, gitignore := range []bool{false, true} {
+		A, err := ParseArea(
+			dir, &config.Config{Gitignore: gitignore}, false,
+		)
+		if err != nil {
+			return fmt.Errorf("cannot parse: %w", err)
+		}
+		if _, ok := A.pages["scratch.md"]; ok == gitignore {
+			return fmt.Errorf(
+				"gitignore %v: scratch.md parsed: %v", gitignore, ok,
+			)
+		}
+	}
+	return nil
+}
+
+func TestSkip(t *testing.T) {
+	if err := testSkip(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testSkip() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := writefiles(dir, map[string]string{
+		"index.md":         "# index",
+		"sub/post.md":      "# post",
+		".cache/ab/abcdef": "cached",
+		"out/index.html":   "<h1>index</h1>",
+		"out/logo.png":     "png",
+	}); err != nil {
+		return err
+	}
+	A, err := ParseArea(
+		dir, &config.Config{Cache: filepath.Join(dir, ".cache")}, false,
+		filepath.Join(dir, "out"),
+	)
+	if err != nil {
+		return fmt.Errorf("cannot parse: %w", err)
+	}
+	if len(A.subareas) != 1 || A.subareas[0].prefix != "sub" {
+		return fmt.Errorf("unexpected subareas %+v", A.subareas)
+	}
+	return nil
+}
+
+func TestPruneCache(t *testing.T) {
+	if err := testPruneCache(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testPruneCache() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	src, target := filepath.Join(dir, "src"), filepath.Join(dir, "out")
+	cfg := &config.Config{
+		Theme: "../../../theme/lit",
+		Cache: filepath.Join(dir, "cache"),
+	}
+	var counts []int
+	for _, post := range []string{"# post", "# edited"} {
+		if err := writefiles(src, map[string]string{
+			"index.md": "# index",
+			"post.md":  post,
+		}); err != nil {
+			return err
+		}
+		A, err := ParseArea(src, cfg, false, target)
+		if err != nil {
+			return fmt.Errorf("cannot parse: %w", err)
+		}
+		if err := A.GenerateSite(
+			target, cfg, areainfo.PurposeStaticServe,
+		); err != nil {
+			return fmt.Errorf("cannot generate: %w", err)
+		}
+		n, err := countfiles(cfg.Cache)
+		if err != nil {
+			return err
+		}
+		counts = append(counts, n)
+	}
+	if counts[0] == 0 || counts[1] != counts[0] {
+		return fmt.Errorf("cache entries after each build: %v", counts)
+	}
+	return nil
+}
+
+func countfiles(dir string) (int, error) {
+	n := 0
+	err := filepath.WalkDir(dir, func(
+		path string, d fs.DirEntry, err error,
+	) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return err
+	})
+	return n, err
+}
+
+func TestTags(t *testing.T) {
+	if err := testTags(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testTags() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := writefiles(dir, map[string]string{
+		"index.md": "# Home",
+		"post.md":  "---\ntags:\n  - Café\n  - a/b\n---\n# Post",
+	}); err != nil {
+		return err
+	}
+	cfg := &config.Config{Theme: "../../../theme/lit"}
+	A, err := ParseArea(dir, cfg, false)
+	if err != nil {
+		return fmt.Errorf("cannot parse: %w", err)
+	}
+	h, err := A.Handler(cfg)
+	if err != nil {
+		return fmt.Errorf("cannot make handler: %w", err)
+	}
+	defer h.Destroy()
+	for url, want := range map[string]string{
+		"/tags":      `href="/tags/cafe"`,
+		"/tags/cafe": "Café",
+		"/tags/a-b":  "a/b",
+	} {
+		if code, body := get(h, url); code != 200 ||
+			!strings.Contains(body, want) {
+			return fmt.Errorf("%s: status %d:\n%s", url, code, body)
+		}
+	}
+	return nil
+}
+
+func TestGitHash(t *testing.T) {
+	if err := testGitHash(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testGitHash() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := writefiles(dir, map[string]string{
+		"index.md": "# index",
+		"post.md":  "# post",
+		"draft.md": "---\ndraft: true\n---\n# draft",
+	}); err != nil {
+		return err
+	}
+	if err := commitall(dir); err != nil {
+		return fmt.Errorf("cannot commit: %w", err)
+	}
+	out := filepath.Join(dir, "out")
+	clean, err := areahash(dir, out)
+	if err != nil {
+		return err
+	}
+	if h, err := areahash(dir, out); err != nil {
+		return err
+	} else if h != clean {
+		return fmt.Errorf("clean tree hash not stable: %s != %s", h, clean)
+	}
+	if err := writefiles(dir, map[string]string{
+		"out/index.html": "<h1>index</h1>",
+		"draft.md":       "---\ndraft: true\n---\n# edited draft",
+	}); err != nil {
+		return err
+	}
+	if h, err := areahash(dir, out); err != nil {
+		return err
+	} else if h != clean {
+		return fmt.Errorf("output or draft changed hash")
+	}
+	prev := clean
+	for _, c := range []struct {
+		name, content string
+	}{
+		{"post.md", "# edited"},
+		{"untracked.md", "# untracked"},
+	} {
+		if err := writefiles(dir, map[string]string{
+			c.name: c.content,
+		}); err != nil {
+			return err
+		}
+		h, err := areahash(dir, out)
+		if err != nil {
+			return err
+		}
+		if h == prev {
+			return fmt.Errorf("writing %q did not change hash", c.name)
+		}
+		prev = h
+	}
+	return nil
+}
+
+// commitall makes dir a git repository and commits everything in it.
+func commitall(dir string) error {
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		return fmt.Errorf("cannot init: %w", err)
+	}
+	_, err = commit(r, "init")
+	return err
+}
+
+// commit commits everything in the worktree of r.
+func commit(r *git.Repository, msg string) (plumbing.Hash, error) {
+	wt, err := r.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("cannot get worktree: %w", err)
+	}
+	if err := wt.AddGlob("."); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("cannot add: %w", err)
+	}
+	return wt.Commit(msg, &git.CommitOptions{
+		Author: &object.Signature{
+			Name: "Ann", Email: "ann@example.com", When: time.Now(),
+		},
+	})
+}
+
+func areahash(dir string, skip ...string) (string, error) {
+	A, err := ParseArea(dir, &config.Config{}, false, skip...)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse: %w", err)
+	}
+	return A.Hash()
+}
+
+// writefiles writes the files, keyed by slash-separated path, under dir.
+func writefiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
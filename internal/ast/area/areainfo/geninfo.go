@@ -1,8 +1,11 @@
 package areainfo
 
 import (
+	"strings"
+
 	"github.com/hylodoc/hyloblog-ssg/internal/assert"
 	"github.com/hylodoc/hyloblog-ssg/internal/ast/page"
+	"github.com/hylodoc/hyloblog-ssg/internal/config"
 	"github.com/hylodoc/hyloblog-ssg/internal/theme"
 )
 
@@ -11,6 +14,13 @@ type GenInfo struct {
 	index      page.Page
 	purpose    Purpose
 	head, foot string
+	baseurl    string
+	sitetitle  string
+	dateformat string
+	robots     string
+	history    string
+	pathprefix string
+	workers    int
 	theme      *theme.Theme
 }
 
@@ -22,6 +32,14 @@ func (info *GenInfo) copy() *GenInfo {
 		purpose: info.purpose,
 		head:    info.head,
 		foot:    info.foot,
+		baseurl: info.baseurl,
+
+		sitetitle:  info.sitetitle,
+		dateformat: info.dateformat,
+		robots:     info.robots,
+		history:    info.history,
+		pathprefix: info.pathprefix,
+		workers:    info.workers,
 	}
 }
 
@@ -47,6 +65,56 @@ func (info *GenInfo) WithHeadFoot(head, foot string) *GenInfo {
 	return gi
 }
 
+func (info *GenInfo) WithBaseURL(baseurl string) *GenInfo {
+	gi := info.copy()
+	gi.baseurl = strings.TrimSuffix(baseurl, "/")
+	return gi
+}
+
+func (info *GenInfo) WithSiteTitle(title string) *GenInfo {
+	gi := info.copy()
+	gi.sitetitle = title
+	return gi
+}
+
+func (info *GenInfo) WithDateFormat(format string) *GenInfo {
+	gi := info.copy()
+	gi.dateformat = format
+	return gi
+}
+
+func (info *GenInfo) WithRobots(robots string) *GenInfo {
+	gi := info.copy()
+	gi.robots = robots
+	return gi
+}
+
+// WithHistoryLink sets the link to the history of the page being generated.
+func (info *GenInfo) WithHistoryLink(link string) *GenInfo {
+	gi := info.copy()
+	gi.history = link
+	return gi
+}
+
+// WithPathPrefix sets the URL path under which the site is mounted. The site
+// must be generated into the corresponding subdirectory of the root.
+func (info *GenInfo) WithPathPrefix(prefix string) *GenInfo {
+	gi := info.copy()
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix = "/" + prefix
+	}
+	gi.pathprefix = prefix
+	return gi
+}
+
+// WithWorkers bounds the number of files written at once. If workers is not
+// positive there is one per CPU.
+func (info *GenInfo) WithWorkers(workers int) *GenInfo {
+	gi := info.copy()
+	gi.workers = workers
+	return gi
+}
+
 func (info *GenInfo) GetIndex() (page.Page, bool) {
 	return info.index, info.index != nil
 }
@@ -67,8 +135,21 @@ func (info *GenInfo) Theme() *theme.Theme { return info.theme }
 func (info *GenInfo) Root() string        { return info.rootdir }
 func (info *GenInfo) Head() string        { return info.head }
 func (info *GenInfo) Foot() string        { return info.foot }
+func (info *GenInfo) BaseURL() string     { return info.baseurl }
+func (info *GenInfo) SiteTitle() string   { return info.sitetitle }
+func (info *GenInfo) Robots() string      { return info.robots }
+func (info *GenInfo) HistoryLink() string { return info.history }
+func (info *GenInfo) PathPrefix() string  { return info.pathprefix }
+func (info *GenInfo) Workers() int        { return info.workers }
 func (info *GenInfo) Binding() bool       { return info.purpose == PurposeBind }
 
+func (info *GenInfo) DateFormat() string {
+	if info.dateformat == "" {
+		return config.DefaultDateFormat
+	}
+	return info.dateformat
+}
+
 type Purpose int
 
 const (
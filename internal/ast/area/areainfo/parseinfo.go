@@ -1,103 +1,155 @@
 package areainfo
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/hylodoc/hyloblog-ssg/internal/assert"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/readdir"
+	"github.com/hylodoc/hyloblog-ssg/internal/cache"
+	"github.com/hylodoc/hyloblog-ssg/internal/gitindex"
 )
 
 type ParseInfo struct {
-	ign         map[string]bool
-	gitdir      string
+	// ign holds the ignore patterns in force, in ascending order of
+	// precedence
+	ign         []gitignore.Pattern
+	git         *gitindex.Index
+	src         *readdir.Source
+	cache       *cache.Cache
 	chromastyle string
+	drafts      bool
 }
 
-func NewParseInfo(chromastyle string) *ParseInfo {
-	return &ParseInfo{map[string]bool{}, "", chromastyle}
+func NewParseInfo(
+	src *readdir.Source, chromastyle string, drafts bool,
+) *ParseInfo {
+	return &ParseInfo{nil, nil, src, nil, chromastyle, drafts}
 }
 
-func (info *ParseInfo) Descend(dir, ignorefile string) (*ParseInfo, error) {
-	ign, err := augmentign(info.ign, filepath.Join(dir, ignorefile))
-	if err != nil {
-		return nil, fmt.Errorf("cannot augment ign: %w", err)
+// WithGitIndex uses git for the history of every page rather than looking for
+// a repository while descending.
+func (info *ParseInfo) WithGitIndex(git *gitindex.Index) *ParseInfo {
+	return &ParseInfo{
+		info.ign, git, info.src, info.cache, info.chromastyle,
+		info.drafts,
+	}
+}
+
+// WithCache takes rendered pages from c where possible.
+func (info *ParseInfo) WithCache(c *cache.Cache) *ParseInfo {
+	return &ParseInfo{
+		info.ign, info.git, info.src, c, info.chromastyle, info.drafts,
+	}
+}
+
+// Descend returns the info for parsing dir, adding the patterns in the
+// ignorefiles found there. Patterns in later files take precedence.
+func (info *ParseInfo) Descend(
+	dir string, ignorefiles ...string,
+) (*ParseInfo, error) {
+	ign := info.ign
+	for _, name := range ignorefiles {
+		var err error
+		ign, err = augmentign(ign, info.src, dir, name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot augment ign: %w", err)
+		}
 	}
-	gitdir, err := augmentgitdir(info.gitdir, dir)
+	git, err := augmentgit(info.git, dir)
 	if err != nil {
-		return nil, fmt.Errorf("cannot check for gitdir: %w", err)
+		return nil, fmt.Errorf("cannot check for git: %w", err)
 	}
-	return &ParseInfo{ign, gitdir, info.chromastyle}, nil
+	return &ParseInfo{
+		ign, git, info.src, info.cache, info.chromastyle, info.drafts,
+	}, nil
 }
 
-func augmentign(oldign map[string]bool, path string) (map[string]bool, error) {
-	ign, err := parseignorefile(path)
+func augmentign(
+	oldign []gitignore.Pattern, src *readdir.Source, dir, name string,
+) ([]gitignore.Pattern, error) {
+	ign, err := parseignorefile(src, dir, name)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get from file: %w", err)
 	}
-	for k, old := range oldign {
-		if _, ok := ign[k]; !ok {
-			ign[k] = old
-		}
+	if len(ign) == 0 {
+		return oldign, nil
 	}
-	return ign, nil
+	return append(append([]gitignore.Pattern{}, oldign...), ign...), nil
 }
 
-func parseignorefile(path string) (map[string]bool, error) {
-	lines, err := getignorelines(path)
+// parseignorefile reads the patterns in the file name in dir, which have the
+// syntax and semantics of a .gitignore there.
+func parseignorefile(
+	src *readdir.Source, dir, name string,
+) ([]gitignore.Pattern, error) {
+	lines, err := getignorelines(src, filepath.Join(dir, name))
 	if err != nil {
 		return nil, fmt.Errorf("cannot get ignore lines: %w", err)
 	}
-	ignore := map[string]bool{}
+	domain, err := splitpath(src, dir)
+	if err != nil {
+		return nil, err
+	}
+	var patterns []gitignore.Pattern
 	for _, line := range lines {
-		inst := parseinstruction(line)
-		ignore[inst.name] = inst.shouldignore
+		if strings.HasPrefix(line, "#") ||
+			strings.TrimSpace(line) == "" {
+			continue
+		}
+		patterns = append(
+			patterns,
+			gitignore.ParsePattern(strings.TrimSuffix(line, "\r"), domain),
+		)
 	}
-	return ignore, nil
+	return patterns, nil
 }
 
-func getignorelines(path string) ([]string, error) {
-	b, err := os.ReadFile(path)
+// splitpath returns the components of path relative to the root of src.
+func splitpath(src *readdir.Source, path string) ([]string, error) {
+	rel, err := src.Rel(path)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []string{}, nil
-		}
-		return nil, fmt.Errorf("cannot read file: %w", err)
+		return nil, err
 	}
-	trimmed := strings.TrimSpace(string(b))
-	if len(trimmed) == 0 {
-		return []string{}, nil
+	if rel == "." {
+		return nil, nil
 	}
-	return strings.Split(trimmed, "\n"), nil
+	return strings.Split(rel, "/"), nil
 }
 
-type instruction struct {
-	name         string
-	shouldignore bool
-}
-
-func parseinstruction(s string) *instruction {
-	assert.Assert(len(s) > 0)
-	if s[0] == '!' {
-		return &instruction{s[1:], false}
+func getignorelines(src *readdir.Source, path string) ([]string, error) {
+	b, err := src.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("cannot read file: %w", err)
 	}
-	return &instruction{s, true}
+	return strings.Split(string(b), "\n"), nil
 }
 
-func augmentgitdir(gitdir, path string) (string, error) {
-	if gitdir != "" {
-		return gitdir, nil
+// augmentgit indexes the history of the repository at path, unless an
+// enclosing one has already been found.
+func augmentgit(git *gitindex.Index, path string) (*gitindex.Index, error) {
+	if git != nil {
+		return git, nil
 	}
-	gitdir = filepath.Join(path, ".git")
-	is, err := isgitdir(gitdir)
+	is, err := isgitdir(filepath.Join(path, ".git"))
 	if err != nil {
-		return "", fmt.Errorf("cannot check if gitdir: %w", err)
+		return nil, fmt.Errorf("cannot check if gitdir: %w", err)
 	}
-	if is {
-		return gitdir, nil
+	if !is {
+		return nil, nil
+	}
+	git, err = gitindex.Build(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot index history: %w", err)
 	}
-	return "", nil
+	return git, nil
 }
 
 func isgitdir(gitdir string) (bool, error) {
@@ -111,15 +163,32 @@ func isgitdir(gitdir string) (bool, error) {
 	return stat.IsDir(), nil
 }
 
-func (info *ParseInfo) ShouldIgnore(name string) bool {
-	shouldignore, ok := info.ign[name]
-	return ok && shouldignore
+// ShouldIgnore reports whether the file or directory at path is excluded by
+// the patterns in force.
+func (info *ParseInfo) ShouldIgnore(path string, isdir bool) bool {
+	components, err := splitpath(info.src, path)
+	if err != nil || components == nil {
+		return false
+	}
+	return gitignore.NewMatcher(info.ign).Match(components, isdir)
+}
+
+func (info *ParseInfo) GitIndex() (*gitindex.Index, bool) {
+	return info.git, info.git != nil
 }
 
-func (info *ParseInfo) GitDir() (string, bool) {
-	return info.gitdir, info.gitdir != ""
+func (info *ParseInfo) Source() *readdir.Source {
+	return info.src
+}
+
+func (info *ParseInfo) Cache() *cache.Cache {
+	return info.cache
 }
 
 func (info *ParseInfo) ChromaStyle() string {
 	return info.chromastyle
 }
+
+func (info *ParseInfo) Drafts() bool {
+	return info.drafts
+}
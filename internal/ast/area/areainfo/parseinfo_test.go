@@ -0,0 +1,60 @@
+package areainfo
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/readdir"
+)
+
+func TestIgnore(t *testing.T) {
+	if err := testIgnore(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testIgnore() error {
+	src := readdir.NewSource("/src", fstest.MapFS{
+		".hyloblogignore": {Data: []byte(
+			"# scratch files\n*.tmp\n/drafts/old.md\nbuild/\n" +
+				"**/scratch/**\n",
+		)},
+		"sub/.hyloblogignore": {Data: []byte("!keep.tmp\n")},
+	})
+	root, err := NewParseInfo(src, "", false).Descend(
+		"/src", ".hyloblogignore",
+	)
+	if err != nil {
+		return fmt.Errorf("cannot descend root: %w", err)
+	}
+	sub, err := root.Descend("/src/sub", ".hyloblogignore")
+	if err != nil {
+		return fmt.Errorf("cannot descend sub: %w", err)
+	}
+	for _, c := range []struct {
+		info   *ParseInfo
+		path   string
+		isdir  bool
+		ignore bool
+	}{
+		{root, "/src/a.tmp", false, true},
+		{root, "/src/a.md", false, false},
+		{root, "/src/# scratch files", false, false},
+		{sub, "/src/sub/b.tmp", false, true},
+		{sub, "/src/sub/keep.tmp", false, false},
+		{root, "/src/drafts/old.md", false, true},
+		{sub, "/src/sub/drafts/old.md", false, false},
+		{root, "/src/build", true, true},
+		{root, "/src/build", false, false},
+		{sub, "/src/sub/x/scratch/y.md", false, true},
+	} {
+		if got := c.info.ShouldIgnore(c.path, c.isdir); got != c.ignore {
+			return fmt.Errorf(
+				"%q (dir: %v): expected ignore %v, got %v",
+				c.path, c.isdir, c.ignore, got,
+			)
+		}
+	}
+	return nil
+}
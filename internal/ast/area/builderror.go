@@ -0,0 +1,105 @@
+package area
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/page"
+)
+
+// excerptLines is how many lines of source are shown either side of the line
+// on which a build failed.
+const excerptLines = 3
+
+// A builderror is a failed build as shown on the error page. File and Line are
+// empty if the failure cannot be traced to a page.
+type builderror struct {
+	File    string
+	Line    int
+	Excerpt []sourceline
+	Err     string
+	Script  template.HTML
+}
+
+type sourceline struct {
+	N       int
+	Text    string
+	Failing bool
+}
+
+func describe(err error) *builderror {
+	b := &builderror{Err: err.Error(), Script: template.HTML(reloadscript)}
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		b.File = perr.Path
+	}
+	var serr *page.SourceError
+	if errors.As(err, &serr) {
+		b.Line = serr.Line
+	}
+	if b.File != "" && b.Line > 0 {
+		b.Excerpt = excerpt(b.File, b.Line)
+	}
+	return b
+}
+
+// excerpt returns the lines of the file at path around line.
+func excerpt(path string, line int) []sourceline {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(string(buf), "\n")
+	var res []sourceline
+	for n := max(1, line-excerptLines); n <= line+excerptLines; n++ {
+		if n > len(lines) {
+			break
+		}
+		res = append(res, sourceline{n, lines[n-1], n == line})
+	}
+	return res
+}
+
+var builderrortmpl = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+	<head>
+		<title>Build failed</title>
+		<style>
+			body { font-family: sans-serif; margin: 2em; }
+			pre { background: #f6f6f6; padding: 1em; overflow-x: auto; }
+			.failing { background: #fdd; display: block; }
+		</style>
+	</head>
+	<body>
+		<h1>Build failed</h1>
+		{{ if .File }}
+		<p><code>{{ .File }}{{ if .Line }}:{{ .Line }}{{ end }}</code></p>
+		{{ end }}
+		{{ if .Excerpt }}
+		<pre>{{ range .Excerpt }}<span{{ if .Failing }} class="failing"{{ end }}>{{ printf "%4d" .N }}  {{ .Text }}</span>
+{{ end }}</pre>
+		{{ end }}
+		<pre>{{ .Err }}</pre>
+		<p>The page reloads once the source is fixed.</p>
+		{{ .Script }}
+	</body>
+</html>
+`))
+
+func serveerror(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	if err := builderrortmpl.Execute(w, describe(err)); err != nil {
+		log.Println("cannot execute error page:", err)
+	}
+}
+
+// wantshtml reports whether r is for a page, e.g. a browser navigating, rather
+// than for something a page embeds.
+func wantshtml(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
@@ -0,0 +1,95 @@
+package area
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/areainfo"
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/page"
+)
+
+// An errorfile is the page of the theme for an HTTP status, generated at the
+// root of the site and served under its path prefix.
+type errorfile struct {
+	status    int
+	url, path string
+}
+
+// errorfiles returns the error pages the theme provides.
+func errorfiles(dir string, g *areainfo.GenInfo) []errorfile {
+	var files []errorfile
+	for _, status := range []int{
+		http.StatusNotFound, http.StatusInternalServerError,
+	} {
+		if !g.Theme().HasErrorPage(status) {
+			continue
+		}
+		name := fmt.Sprint(status)
+		files = append(files, errorfile{
+			status,
+			g.PathPrefix() + "/" + name + hostext(g),
+			filepath.Join(dir, name+".html"),
+		})
+	}
+	return files
+}
+
+func generateerrorpages(dir string, g *areainfo.GenInfo) error {
+	index, _ := g.GetIndex()
+	for _, ef := range errorfiles(dir, g) {
+		if err := writefile(ef.path, func(w io.Writer) error {
+			return page.GenerateErrorPage(w, ef.status, g, index)
+		}); err != nil {
+			return fmt.Errorf("cannot write %q: %w", ef.path, err)
+		}
+	}
+	return nil
+}
+
+// errorpages maps the statuses for which the theme provides pages to the
+// paths they are generated into.
+func errorpages(dir string, g *areainfo.GenInfo) map[int]string {
+	m := map[int]string{}
+	for _, ef := range errorfiles(dir, g) {
+		m[ef.status] = ef.path
+	}
+	return m
+}
+
+// serveerrorpage responds with status, using the page at path if there is one.
+func serveerrorpage(w http.ResponseWriter, path string, status int) {
+	if path != "" {
+		if b, err := os.ReadFile(path); err == nil {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(status)
+			w.Write(b)
+			return
+		}
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+func errorpagehandler(path string, status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serveerrorpage(w, path, status)
+	}
+}
+
+// notfoundbyprefix responds with the 404 page of the site mounted at the
+// longest path prefix in pages that the request falls under.
+func notfoundbyprefix(pages map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var prefix string
+		for p := range pages {
+			if (r.URL.Path == p || strings.HasPrefix(r.URL.Path, p+"/")) &&
+				len(p) > len(prefix) {
+				prefix = p
+			}
+		}
+		serveerrorpage(w, pages[prefix], http.StatusNotFound)
+	}
+}
@@ -0,0 +1,93 @@
+package area
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/areainfo"
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/page"
+)
+
+type feedfile struct {
+	name   string
+	format page.FeedFormat
+}
+
+var feeds = []feedfile{
+	{"feed.xml", page.FeedRSS},
+	{"atom.xml", page.FeedAtom},
+	{"feed.json", page.FeedJSON},
+}
+
+// hasfeeds reports whether the area has feeds. Only areas with an index have
+// them, and only if a base URL is configured, since feed links must be
+// absolute.
+func (A *Area) hasfeeds(g *areainfo.GenInfo) bool {
+	_, ok := A.pages[indexFile]
+	return ok && g.BaseURL() != ""
+}
+
+// feedhostpaths maps the host path of each feed of the area to the path on
+// disk it is generated into.
+func (A *Area) feedhostpaths(
+	dir string, g *areainfo.GenInfo,
+) (map[string]string, error) {
+	if !A.hasfeeds(g) {
+		return map[string]string{}, nil
+	}
+	m := map[string]string{}
+	for _, f := range feeds {
+		path, err := filehostpath(f.name, dir, g.Root())
+		if err != nil {
+			return nil, fmt.Errorf(
+				"cannot make path for %q: %w", f.name, err,
+			)
+		}
+		m[path] = filepath.Join(dir, f.name)
+	}
+	return m, nil
+}
+
+func (A *Area) generatefeeds(dir string, g *areainfo.GenInfo) error {
+	if !A.hasfeeds(g) {
+		if _, ok := A.pages[indexFile]; ok {
+			log.Printf(
+				"warning: no base URL, skipping feeds in %q\n", dir,
+			)
+		}
+		return nil
+	}
+	index := A.pages[indexFile]
+	link, err := pagehostpath(index, indexFile, dir, g)
+	if err != nil {
+		return fmt.Errorf("cannot make index path: %w", err)
+	}
+	posts := A.getposts(dir, g)
+	for _, f := range feeds {
+		self, err := filehostpath(f.name, dir, g.Root())
+		if err != nil {
+			return fmt.Errorf(
+				"cannot make path for %q: %w", f.name, err,
+			)
+		}
+		if err := writefeed(
+			filepath.Join(dir, f.name), f.format,
+			posts, g, index, link, self,
+		); err != nil {
+			return fmt.Errorf("cannot write %q: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+func writefeed(
+	path string, format page.FeedFormat,
+	posts []page.Post, g *areainfo.GenInfo, index page.Page,
+	link, self string,
+) error {
+	return writefile(path, func(w io.Writer) error {
+		return page.GenerateFeed(w, format, posts, g, index, link, self)
+	})
+}
@@ -0,0 +1,94 @@
+package area
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/areainfo"
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/page"
+)
+
+// A historyfile is a generated history page of a post, or one of its
+// revisions when rev is non-negative.
+type historyfile struct {
+	url, path string
+	rev       int
+}
+
+// historyfiles returns the history files of the page with the given name,
+// along with the links passed to its templates. Index pages, custom pages and
+// themes without history templates have none.
+func historyfiles(
+	pg page.Page, name, dir string, g *areainfo.GenInfo,
+) ([]historyfile, *page.HistoryLinks, error) {
+	if name == indexFile || !pg.HasHistory() || !g.Theme().HasHistory() {
+		return nil, nil, nil
+	}
+	pagelink, err := pagehostpath(pg, name, dir, g)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get page path: %w", err)
+	}
+	file := func(suffix string, rev int) (historyfile, error) {
+		url, err := filehostpath(
+			replaceext(name, suffix+hostext(g)), dir, g.Root(),
+		)
+		if err != nil {
+			return historyfile{}, err
+		}
+		return historyfile{
+			url, filepath.Join(dir, replaceext(name, suffix+".html")), rev,
+		}, nil
+	}
+	history, err := file("_history", -1)
+	if err != nil {
+		return nil, nil, err
+	}
+	links := &page.HistoryLinks{Page: pagelink, History: history.url}
+	files := []historyfile{history}
+	for i, hash := range pg.Revisions() {
+		rev, err := file("_history_"+page.ShortHash(hash), i)
+		if err != nil {
+			return nil, nil, err
+		}
+		links.Revisions = append(links.Revisions, rev.url)
+		files = append(files, rev)
+	}
+	return files, links, nil
+}
+
+func hostext(g *areainfo.GenInfo) string {
+	if g.DynamicLinks() {
+		return ""
+	}
+	return ".html"
+}
+
+func generatehistory(
+	pg page.Page, name, dir string, g *areainfo.GenInfo,
+) error {
+	files, links, err := historyfiles(pg, name, dir, g)
+	if err != nil {
+		return err
+	}
+	index, _ := g.GetIndex()
+	for _, hf := range files {
+		if err := writehistoryfile(hf, pg, links, g, index); err != nil {
+			return fmt.Errorf("cannot write %q: %w", hf.path, err)
+		}
+	}
+	return nil
+}
+
+func writehistoryfile(
+	hf historyfile,
+	pg page.Page, links *page.HistoryLinks, g *areainfo.GenInfo,
+	index page.Page,
+) error {
+	return writefile(hf.path, func(w io.Writer) error {
+		if hf.rev < 0 {
+			return pg.GenerateHistory(w, g, index, links)
+		}
+		return pg.GenerateRevision(w, g, index, links, hf.rev)
+	})
+}
@@ -0,0 +1,198 @@
+package area
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/config"
+	"github.com/hylodoc/hyloblog-ssg/internal/watch"
+)
+
+// A LiveHandler serves the last good build of a source directory, rebuilding
+// it whenever files under the source or theme change. Served pages reload
+// themselves when a rebuild changes them.
+type LiveHandler struct {
+	src    string
+	flags  *config.Config
+	drafts bool
+
+	mu  sync.RWMutex
+	h   *Handler
+	err error
+
+	reload reloader
+}
+
+// CreateLiveHandler builds src and starts watching it. The configuration file
+// is reread on each build, with flags overriding it. A failed build is logged
+// and the previous one kept.
+func CreateLiveHandler(
+	src string, flags *config.Config, drafts bool,
+) *LiveHandler {
+	lh := &LiveHandler{src: src, flags: flags, drafts: drafts}
+	cfg, err := lh.rebuild()
+	if err != nil {
+		log.Printf("build failed: %v\n", err)
+		cfg = WatchConfig(src, flags)
+	}
+	go func() {
+		if err := Watch(src, nil, cfg, lh.rebuild); err != nil {
+			log.Printf("cannot watch: %v\n", err)
+		}
+	}()
+	return lh
+}
+
+func (lh *LiveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == reloadPath {
+		lh.reload.ServeHTTP(w, r)
+		return
+	}
+	lh.mu.RLock()
+	defer lh.mu.RUnlock()
+	// pages show why the build failed until it is fixed, while whatever they
+	// embed comes from the last good build
+	if lh.h == nil || (lh.err != nil && wantshtml(r)) {
+		serveerror(w, lh.err)
+		return
+	}
+	withreloadscript(lh.h, w, r)
+}
+
+// Destroy removes the current build.
+func (lh *LiveHandler) Destroy() error {
+	lh.mu.Lock()
+	defer lh.mu.Unlock()
+	if lh.h == nil {
+		return nil
+	}
+	return lh.h.Destroy()
+}
+
+// CloseStreams ends the live reload streams, which otherwise stay open until
+// the browser leaves, so that a server shutting down needn't wait for them.
+func (lh *LiveHandler) CloseStreams() {
+	lh.reload.close()
+}
+
+// rebuild replaces the handler with a fresh build, removing the old one once
+// the requests it is serving are done. Every page is reloaded when the build
+// starts or stops failing.
+func (lh *LiveHandler) rebuild() (*config.Config, error) {
+	cfg, h, err := lh.genhandler()
+	lh.mu.Lock()
+	old, failed := lh.h, lh.err != nil
+	if err == nil {
+		lh.h = h
+	}
+	lh.err = err
+	lh.mu.Unlock()
+	if err != nil {
+		lh.reload.broadcast(reloadevent{All: true})
+		return nil, err
+	}
+	ev := reloadevent{All: failed}
+	if old != nil {
+		ev = changes(old, h)
+		ev.All = ev.All || failed
+		if err := old.Destroy(); err != nil {
+			log.Printf("cannot remove old build: %v\n", err)
+		}
+	}
+	if ev.All || len(ev.Paths) > 0 {
+		lh.reload.broadcast(ev)
+	}
+	return cfg, nil
+}
+
+func (lh *LiveHandler) genhandler() (*config.Config, *Handler, error) {
+	cfg, err := config.Load(lh.src)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot load config: %w", err)
+	}
+	cfg = cfg.Override(lh.flags)
+	blog, err := ParseArea(lh.src, cfg, lh.drafts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot parse: %w", err)
+	}
+	if err := blog.Inject(cfg.Pages()); err != nil {
+		return nil, nil, fmt.Errorf("cannot inject: %w", err)
+	}
+	h, err := blog.Handler(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get handler: %w", err)
+	}
+	return cfg, h, nil
+}
+
+// WatchConfig returns the configuration file in src with flags overriding it,
+// or flags alone if the file cannot be loaded. It tells Watch where the theme
+// and render cache are when no build has succeeded yet.
+func WatchConfig(src string, flags *config.Config) *config.Config {
+	cfg, err := config.Load(src)
+	if err != nil {
+		return flags
+	}
+	return cfg.Override(flags)
+}
+
+// Watch waits for files under src or the theme of cfg to change and then calls
+// build, logging its error if it fails, over and over. Changes to the paths in
+// skip, such as a target directory inside src, and to the render cache are
+// disregarded. The configuration returned by a successful build replaces cfg,
+// which may be nil if none is known. Watch only returns if watching fails.
+func Watch(
+	src string, skip []string, cfg *config.Config,
+	build func() (*config.Config, error),
+) error {
+	var w *watch.Watcher
+	var watched string
+	defer func() {
+		if w != nil {
+			w.Close()
+		}
+	}()
+	for {
+		dirs, ignored := watchpaths(src, skip, cfg)
+		key := strings.Join(dirs, "\x00") + "\x01" +
+			strings.Join(ignored, "\x00")
+		if key != watched {
+			if w != nil {
+				if err := w.Close(); err != nil {
+					return fmt.Errorf("cannot stop watching: %w", err)
+				}
+			}
+			var err error
+			if w, err = watch.New(dirs, ignored); err != nil {
+				return fmt.Errorf("cannot watch: %w", err)
+			}
+			watched = key
+		}
+		<-w.Changes()
+		log.Println("change detected, rebuilding...")
+		if c, err := build(); err != nil {
+			log.Printf("build failed: %v\n", err)
+		} else {
+			cfg = c
+		}
+	}
+}
+
+func watchpaths(
+	src string, skip []string, cfg *config.Config,
+) (dirs, ignored []string) {
+	dirs, ignored = []string{src}, append([]string{}, skip...)
+	if cfg == nil {
+		return dirs, ignored
+	}
+	if cfg.Theme != "" {
+		dirs = append(dirs, cfg.Theme)
+	}
+	if cfg.Cache != "" {
+		ignored = append(ignored, cfg.Cache)
+	}
+	return dirs, ignored
+}
@@ -0,0 +1,191 @@
+package area
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/config"
+)
+
+func TestWatchpaths(t *testing.T) {
+	if err := testWatchpaths(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testWatchpaths() error {
+	dirs, ignored := watchpaths("/src", []string{"/src/out"}, nil)
+	if !reflect.DeepEqual(dirs, []string{"/src"}) ||
+		!reflect.DeepEqual(ignored, []string{"/src/out"}) {
+		return fmt.Errorf("no config: watched %q, ignored %q", dirs, ignored)
+	}
+	dirs, ignored = watchpaths(
+		"/src", []string{"/src/out"},
+		&config.Config{Theme: "/theme", Cache: "/src/.cache"},
+	)
+	if !reflect.DeepEqual(dirs, []string{"/src", "/theme"}) ||
+		!reflect.DeepEqual(ignored, []string{"/src/out", "/src/.cache"}) {
+		return fmt.Errorf("watched %q, ignored %q", dirs, ignored)
+	}
+	return nil
+}
+
+func TestWatchConfig(t *testing.T) {
+	if err := testWatchConfig(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testWatchConfig() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	flags := &config.Config{Cache: "/cache"}
+	for _, c := range []struct {
+		file, theme string
+	}{
+		{"theme: /theme\n", "/theme"},
+		{"theme: [\n", ""},
+	} {
+		if err := writefiles(dir, map[string]string{
+			config.File: c.file,
+		}); err != nil {
+			return err
+		}
+		cfg := WatchConfig(dir, flags)
+		if cfg.Theme != c.theme || cfg.Cache != "/cache" {
+			return fmt.Errorf(
+				"%q: got theme %q, cache %q",
+				c.file, cfg.Theme, cfg.Cache,
+			)
+		}
+	}
+	return nil
+}
+
+func TestLiveHandler(t *testing.T) {
+	if err := testLiveHandler(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testLiveHandler() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	const broken = "---\ntitle: [\n---\n# Post"
+	if err := writefiles(dir, map[string]string{
+		"index.md": "# Home",
+		"post.md":  broken,
+		"logo.png": "png",
+	}); err != nil {
+		return err
+	}
+	lh := &LiveHandler{
+		src: dir, flags: &config.Config{Theme: "../../../theme/lit"},
+	}
+	defer lh.Destroy()
+	ch := lh.reload.subscribe()
+
+	// without a good build everything shows the error
+	if _, err := lh.rebuild(); err == nil {
+		return fmt.Errorf("broken page built")
+	}
+	<-ch
+	for _, c := range []struct {
+		url  string
+		page bool
+	}{
+		{"/post", true},
+		{"/logo.png", false},
+	} {
+		if err := expect(lh, c.url, c.page, 500, "post.md"); err != nil {
+			return err
+		}
+	}
+
+	if err := writefiles(dir, map[string]string{
+		"post.md": "# Post\n\nFixed.",
+	}); err != nil {
+		return err
+	}
+	if _, err := lh.rebuild(); err != nil {
+		return fmt.Errorf("cannot rebuild: %w", err)
+	}
+	if ev := <-ch; !ev.All {
+		return fmt.Errorf("recovery did not reload every page: %+v", ev)
+	}
+	for _, c := range []struct {
+		url  string
+		code int
+		want string
+	}{
+		{"/post", 200, "Fixed."},
+		{"/post", 200, reloadPath},
+		{"/nope", 404, reloadPath},
+	} {
+		if err := expect(lh, c.url, true, c.code, c.want); err != nil {
+			return err
+		}
+	}
+
+	if err := writefiles(dir, map[string]string{
+		"post.md": "# Post\n\nEdited.",
+	}); err != nil {
+		return err
+	}
+	if _, err := lh.rebuild(); err != nil {
+		return fmt.Errorf("cannot rebuild: %w", err)
+	}
+	if ev := <-ch; ev.All || !contains(ev.Paths, "/post") {
+		return fmt.Errorf("edit: unexpected event %+v", ev)
+	}
+
+	if err := writefiles(dir, map[string]string{
+		"post.md": broken,
+	}); err != nil {
+		return err
+	}
+	if _, err := lh.rebuild(); err == nil {
+		return fmt.Errorf("broken page built")
+	}
+	if ev := <-ch; !ev.All {
+		return fmt.Errorf("failure did not reload every page: %+v", ev)
+	}
+	// pages show the error, while what they embed comes from the last good
+	// build
+	if err := expect(lh, "/post", true, 500, "post.md"); err != nil {
+		return err
+	}
+	if err := expect(lh, "/logo.png", false, 200, "png"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// expect checks that h responds to a GET of url, made by a browser navigating
+// if page is set, with code and a body containing want.
+func expect(h http.Handler, url string, page bool, code int, want string) error {
+	r := httptest.NewRequest("GET", url, nil)
+	if page {
+		r.Header.Set("Accept", "text/html")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != code || !strings.Contains(w.Body.String(), want) {
+		return fmt.Errorf(
+			"%s: expected %d with %q, got %d:\n%s",
+			url, code, want, w.Code, w.Body,
+		)
+	}
+	return nil
+}
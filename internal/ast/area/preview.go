@@ -0,0 +1,145 @@
+package area
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/areainfo"
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/page"
+	"github.com/hylodoc/hyloblog-ssg/internal/config"
+	"github.com/hylodoc/hyloblog-ssg/internal/gitindex"
+)
+
+const previewPath = "/_preview"
+
+// A previewbranch is a branch as listed on the preview index. Err is set if
+// the branch could not be generated.
+type previewbranch struct {
+	Name, Link, ShortHash, Subject, Author, Date, Err string
+}
+
+// PreviewHandler serves each local branch of the git repository at repo,
+// generated from its tree, under /_preview/<branch>. Every branch uses its own
+// configuration file overridden by flags. The branches are listed at
+// /_preview, to which / redirects. Paths not found under a branch get its 404
+// page.
+func PreviewHandler(
+	repo string, flags *config.Config, drafts bool,
+) (*Handler, error) {
+	branches, err := gitindex.Branches(repo)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get branches: %w", err)
+	}
+	target, err := os.MkdirTemp("", "")
+	if err != nil {
+		return nil, fmt.Errorf("cannot make tempdir: %w", err)
+	}
+	r := mux.NewRouter()
+	r.StrictSlash(true)
+	h := &Handler{r, target, map[string]string{}, map[int]string{}}
+	listed := make([]previewbranch, len(branches))
+	notfound := map[string]string{}
+	for i, b := range branches {
+		link := previewPath + "/" + b.Name
+		listed[i] = previewbranch{
+			Name:      b.Name,
+			Link:      link,
+			ShortHash: page.ShortHash(b.Head.Hash),
+			Subject:   b.Head.Subject(),
+			Author:    b.Head.Author.Name,
+			Date:      b.Head.Author.When.Format(config.DefaultDateFormat),
+		}
+		pages, err := mountbranch(
+			repo, b.Name, link, target, flags, drafts, h.route(r),
+		)
+		if err != nil {
+			log.Printf("warning: cannot preview %q: %v\n", b.Name, err)
+			listed[i].Err = err.Error()
+			continue
+		}
+		notfound[link] = pages[http.StatusNotFound]
+	}
+	r.NotFoundHandler = notfoundbyprefix(notfound)
+	r.HandleFunc(previewPath, previewindex(listed))
+	r.Handle("/", http.RedirectHandler(previewPath, http.StatusFound))
+	return h, nil
+}
+
+// mountbranch generates branch into the subdirectory of target corresponding
+// to prefix and registers its handlers there, returning its error pages.
+func mountbranch(
+	repo, branch, prefix, target string,
+	flags *config.Config, drafts bool, route func(url, path string),
+) (map[int]string, error) {
+	ref, err := OpenRef(repo, branch)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open: %w", err)
+	}
+	cfg, err := ref.Config()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load config: %w", err)
+	}
+	cfg = cfg.Override(flags)
+	// absolute URLs would point at the live site rather than the preview
+	cfg.BaseURL = ""
+	A, err := ref.Parse(cfg, drafts)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse: %w", err)
+	}
+	if err := A.Inject(cfg.Pages()); err != nil {
+		return nil, fmt.Errorf("cannot inject: %w", err)
+	}
+	g, err := newgeninfo(target, cfg, areainfo.PurposeDynamicServe)
+	if err != nil {
+		return nil, err
+	}
+	// the branch is mounted at prefix whatever path prefix it configures
+	g = g.WithPathPrefix(prefix)
+	dir := sitedir(target, g)
+	if err := A.generate(dir, g); err != nil {
+		return nil, fmt.Errorf("cannot generate: %w", err)
+	}
+	if err := A.registerhandlers(dir, g, route); err != nil {
+		return nil, err
+	}
+	return errorpages(dir, g), nil
+}
+
+var previewtmpl = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+	<head>
+		<title>Branch previews</title>
+	</head>
+	<body>
+		<h1>Branch previews</h1>
+		<ul>
+		{{ range . }}
+			<li>
+				{{ if .Err }}
+				{{ .Name }}
+				{{ else }}
+				<a href="{{ .Link }}">{{ .Name }}</a>
+				{{ end }}
+				<code>{{ .ShortHash }}</code> {{ .Subject }}
+				<br><small>{{ .Author }} · {{ .Date }}</small>
+				{{ if .Err }}
+				<br><small>cannot preview: {{ .Err }}</small>
+				{{ end }}
+			</li>
+		{{ end }}
+		</ul>
+	</body>
+</html>
+`))
+
+func previewindex(branches []previewbranch) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := previewtmpl.Execute(w, branches); err != nil {
+			log.Println("cannot execute preview index:", err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package area
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/page"
+	"github.com/hylodoc/hyloblog-ssg/internal/config"
+)
+
+func TestPreview(t *testing.T) {
+	if err := testPreview(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testPreview() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := writefiles(dir, map[string]string{
+		"index.md": "# Home",
+		"post.md":  "# Post",
+	}); err != nil {
+		return err
+	}
+	if err := commitall(dir); err != nil {
+		return fmt.Errorf("cannot commit: %w", err)
+	}
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return err
+	}
+	main, err := r.Head()
+	if err != nil {
+		return err
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName("draft"),
+		Create: true,
+	}); err != nil {
+		return fmt.Errorf("cannot checkout: %w", err)
+	}
+	if err := writefiles(dir, map[string]string{
+		"index.md": "# Draft Home",
+		"new.md":   "# New\n\nAn unmerged post.",
+	}); err != nil {
+		return err
+	}
+	draft, err := commit(r, "add new post")
+	if err != nil {
+		return fmt.Errorf("cannot commit: %w", err)
+	}
+
+	h, err := PreviewHandler(
+		dir, &config.Config{Theme: "../../../theme/lit"}, false,
+	)
+	if err != nil {
+		return fmt.Errorf("cannot make handler: %w", err)
+	}
+	defer h.Destroy()
+	code, body := get(h, previewPath)
+	if code != http.StatusOK {
+		return fmt.Errorf("index: status %d", code)
+	}
+	for name, head := range map[string]plumbing.Hash{
+		main.Name().Short(): main.Hash(),
+		"draft":             draft,
+	} {
+		link := fmt.Sprintf(`href="%s/%s"`, previewPath, name)
+		hash := page.ShortHash(head.String())
+		if !strings.Contains(body, link) || !strings.Contains(body, hash) {
+			return fmt.Errorf("index lacks %q at %s:\n%s", name, hash, body)
+		}
+	}
+	base := previewPath + "/" + main.Name().Short()
+	const (
+		notfound      = "<title>Not Found | Home</title>"
+		draftnotfound = "<title>Not Found | Draft Home</title>"
+	)
+	for _, c := range []struct {
+		url, want string
+		code      int
+	}{
+		{previewPath + "/draft/new", "An unmerged post.", http.StatusOK},
+		{base + "/post", "Post", http.StatusOK},
+		{base + "/new", notfound, http.StatusNotFound},
+		{base + "/404", notfound, http.StatusOK},
+		{previewPath + "/draft/missing", draftnotfound, http.StatusNotFound},
+		{previewPath + "/draft/404", draftnotfound, http.StatusOK},
+		{"/missing", "", http.StatusNotFound},
+	} {
+		code, body := get(h, c.url)
+		if code != c.code || !strings.Contains(body, c.want) {
+			return fmt.Errorf("%s: status %d:\n%s", c.url, code, body)
+		}
+	}
+	return nil
+}
+
+// get returns the status and body of the response of h to a GET of url.
+func get(h http.Handler, url string) (int, string) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+	return w.Code, w.Body.String()
+}
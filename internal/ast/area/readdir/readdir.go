@@ -1,7 +1,10 @@
 package readdir
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 
@@ -15,6 +18,7 @@ type Directory interface {
 
 type File interface {
 	Path() string
+	Open() (io.ReadCloser, error)
 }
 
 type directory struct {
@@ -25,39 +29,102 @@ type directory struct {
 func (d *directory) Files() []File       { return d.files }
 func (d *directory) Directories() []File { return d.directories }
 
-func ReadDir(dir string) (Directory, error) {
-	entries, err := os.ReadDir(dir)
+// A Source is a tree of files read through fsys. Paths given to its methods
+// are below root, which needn't exist on disk.
+type Source struct {
+	root string
+	fsys fs.FS
+}
+
+func NewSource(root string, fsys fs.FS) *Source {
+	return &Source{root, fsys}
+}
+
+// DirSource reads the directory at root on disk.
+func DirSource(root string) *Source {
+	return NewSource(root, os.DirFS(root))
+}
+
+// Rel returns the slash-separated path of path relative to the root, which is
+// "." for the root itself.
+func (s *Source) Rel(path string) (string, error) {
+	rel, err := filepath.Rel(s.root, path)
 	if err != nil {
-		return nil, fmt.Errorf("os read error: %w", err)
+		return "", fmt.Errorf("cannot get relative path: %w", err)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func (s *Source) ReadDir(dir string) (Directory, error) {
+	name, err := s.Rel(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("read error: %w", err)
 	}
 	return &directory{
-		getfiles(dir, entries), getdirectories(dir, entries),
+		s.getfiles(dir, entries), s.getdirectories(dir, entries),
 	}, nil
 }
 
-type file string
+func (s *Source) ReadFile(path string) ([]byte, error) {
+	name, err := s.Rel(path)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(s.fsys, name)
+}
+
+func (s *Source) Exists(path string) (bool, error) {
+	name, err := s.Rel(path)
+	if err != nil {
+		return false, err
+	}
+	if _, err := fs.Stat(s.fsys, name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+type file struct {
+	path string
+	src  *Source
+}
 
-func NewFile(path string) File { return file(path) }
+func (f *file) Path() string { return f.path }
 
-func (f file) Path() string { return string(f) }
+func (f *file) Open() (io.ReadCloser, error) {
+	name, err := f.src.Rel(f.path)
+	if err != nil {
+		return nil, err
+	}
+	return f.src.fsys.Open(name)
+}
 
-func getfiles(dir string, entries []os.DirEntry) []File {
+func (s *Source) getfiles(dir string, entries []fs.DirEntry) []File {
 	var files []File
 	for _, e := range entries {
 		if !e.IsDir() {
 			t := e.Type()
 			assert.Printf(t.IsRegular(), "unknown file type %v", t)
-			files = append(files, file(filepath.Join(dir, e.Name())))
+			files = append(
+				files, &file{filepath.Join(dir, e.Name()), s},
+			)
 		}
 	}
 	return files
 }
 
-func getdirectories(dir string, entries []os.DirEntry) []File {
+func (s *Source) getdirectories(dir string, entries []fs.DirEntry) []File {
 	var dirs []File
 	for _, e := range entries {
 		if e.IsDir() {
-			dirs = append(dirs, file(filepath.Join(dir, e.Name())))
+			dirs = append(dirs, &file{filepath.Join(dir, e.Name()), s})
 		}
 	}
 	return dirs
@@ -0,0 +1,56 @@
+package area
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/areainfo"
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/readdir"
+	"github.com/hylodoc/hyloblog-ssg/internal/config"
+	"github.com/hylodoc/hyloblog-ssg/internal/gitindex"
+)
+
+// A Ref is a commit of a git repository whose tree is read directly from the
+// object store, so that neither a checkout nor a working tree is needed.
+type Ref struct {
+	repo string
+	git  *gitindex.Index
+	fsys fs.FS
+}
+
+// OpenRef resolves ref, which may be a branch, tag or commit hash, in the
+// repository at repo. The repository may be bare.
+func OpenRef(repo, ref string) (*Ref, error) {
+	git, err := gitindex.BuildRef(repo, ref)
+	if err != nil {
+		return nil, fmt.Errorf("cannot index history: %w", err)
+	}
+	fsys, err := git.FS()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read tree: %w", err)
+	}
+	return &Ref{repo, git, fsys}, nil
+}
+
+// Config loads the configuration file in the tree. A relative theme directory
+// is resolved against the repository.
+func (r *Ref) Config() (*config.Config, error) {
+	return config.LoadFS(r.fsys, r.repo)
+}
+
+// Parse parses the tree as ParseArea does a directory. Timing and authorship
+// come from the history reachable from the ref, and the hash is that of the
+// commit.
+func (r *Ref) Parse(cfg *config.Config, drafts bool) (*Area, error) {
+	c := opencache(cfg)
+	info := areainfo.NewParseInfo(
+		readdir.NewSource(r.repo, r.fsys), cfg.ChromaStyle, drafts,
+	).WithGitIndex(r.git).WithCache(c)
+	A, err := parseall(r.repo, info, newwalk(cfg, nil), cfg.Workers)
+	if err != nil {
+		return nil, err
+	}
+	A.cache = c
+	A.hash = r.git.Head()
+	return A, nil
+}
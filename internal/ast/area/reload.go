@@ -0,0 +1,193 @@
+package area
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// reloadPath is where a live server streams reload events.
+const reloadPath = "/_livereload"
+
+// reloadscript is added to served pages. It reloads the page once a rebuild
+// changes it or any image, which it might embed.
+const reloadscript = `<script>
+new EventSource("` + reloadPath + `").addEventListener("reload", function (e) {
+	var ev = JSON.parse(e.data), here = location.pathname;
+	if (ev.all || ev.paths.indexOf(here) >= 0 ||
+		ev.paths.indexOf(here.replace(/\/$/, "")) >= 0) {
+		location.reload();
+	}
+});
+</script>
+`
+
+// A reloadevent is sent to the browsers when a rebuild changes the files
+// served under paths. All is set if every page should be reloaded.
+type reloadevent struct {
+	Paths []string `json:"paths"`
+	All   bool     `json:"all"`
+}
+
+// A reloader streams reload events to the connected browsers.
+type reloader struct {
+	mu      sync.Mutex
+	clients map[chan reloadevent]struct{}
+	closed  bool
+}
+
+// subscribe returns the channel of events for a new stream, which is nil once
+// the reloader is closed.
+func (rl *reloader) subscribe() chan reloadevent {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.closed {
+		return nil
+	}
+	if rl.clients == nil {
+		rl.clients = map[chan reloadevent]struct{}{}
+	}
+	ch := make(chan reloadevent, 1)
+	rl.clients[ch] = struct{}{}
+	return ch
+}
+
+func (rl *reloader) unsubscribe(ch chan reloadevent) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	delete(rl.clients, ch)
+}
+
+// close ends every stream and refuses new ones.
+func (rl *reloader) close() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.closed = true
+	for ch := range rl.clients {
+		close(ch)
+		delete(rl.clients, ch)
+	}
+}
+
+// broadcast sends ev to every browser that has taken the last one.
+func (rl *reloader) broadcast(ev reloadevent) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	for ch := range rl.clients {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (rl *reloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("cannot clear write deadline: %v\n", err)
+	}
+	ch := rl.subscribe()
+	if ch == nil {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer rl.unsubscribe(ch)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := rc.Flush(); err != nil {
+		http.Error(w, "cannot stream", http.StatusInternalServerError)
+		return
+	}
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			b, err := json.Marshal(ev)
+			if err != nil {
+				log.Printf("cannot marshal reload event: %v\n", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: reload\ndata: %s\n\n", b)
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// changes returns the event describing how the files served by new differ
+// from those served by old.
+func changes(old, new *Handler) reloadevent {
+	var ev reloadevent
+	for url, path := range new.routes {
+		if oldpath, ok := old.routes[url]; !ok || !samefile(oldpath, path) {
+			ev.Paths = append(ev.Paths, url)
+			ev.All = ev.All || includefile(path)
+		}
+	}
+	for url, path := range old.routes {
+		if _, ok := new.routes[url]; !ok {
+			ev.Paths = append(ev.Paths, url)
+			ev.All = ev.All || includefile(path)
+		}
+	}
+	sort.Strings(ev.Paths)
+	return ev
+}
+
+func samefile(a, b string) bool {
+	x, err := os.ReadFile(a)
+	if err != nil {
+		return false
+	}
+	y, err := os.ReadFile(b)
+	return err == nil && bytes.Equal(x, y)
+}
+
+// withreloadscript serves r with h, adding the reload script to the end of the
+// body of complete HTML responses, including not found pages so that they
+// reload once the page is written.
+func withreloadscript(h http.Handler, w http.ResponseWriter, r *http.Request) {
+	buf := &bufferedresponse{header: w.Header(), status: http.StatusOK}
+	h.ServeHTTP(buf, r)
+	body := buf.body.Bytes()
+	if (buf.status == http.StatusOK || buf.status == http.StatusNotFound) &&
+		r.Method != http.MethodHead &&
+		bytes.HasPrefix(
+			[]byte(w.Header().Get("Content-Type")), []byte("text/html"),
+		) {
+		i := bytes.LastIndex(body, []byte("</body>"))
+		if i < 0 {
+			i = len(body)
+		}
+		body = append(
+			body[:i:i], append([]byte(reloadscript), body[i:]...)...,
+		)
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	}
+	w.WriteHeader(buf.status)
+	w.Write(body)
+}
+
+// A bufferedresponse holds a response so that it can be changed before being
+// written.
+type bufferedresponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedresponse) Header() http.Header         { return b.header }
+func (b *bufferedresponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+func (b *bufferedresponse) WriteHeader(status int)      { b.status = status }
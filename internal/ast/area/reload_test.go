@@ -0,0 +1,121 @@
+package area
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChanges(t *testing.T) {
+	if err := testChanges(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testChanges() error {
+	olddir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(olddir)
+	newdir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(newdir)
+	old, new := &Handler{routes: map[string]string{}},
+		&Handler{routes: map[string]string{}}
+	for _, f := range []struct {
+		h              *Handler
+		dir, url, name string
+		content        string
+	}{
+		{old, olddir, "/same", "same.html", "same"},
+		{new, newdir, "/same", "same.html", "same"},
+		{old, olddir, "/edited", "edited.html", "before"},
+		{new, newdir, "/edited", "edited.html", "after"},
+		{old, olddir, "/removed", "removed.html", "removed"},
+		{new, newdir, "/added", "added.html", "added"},
+		{old, olddir, "/logo.png", "logo.png", "png"},
+		{new, newdir, "/logo.png", "logo.png", "png"},
+	} {
+		path, err := touch(f.dir, f.name, f.content)
+		if err != nil {
+			return err
+		}
+		f.h.routes[f.url] = path
+	}
+	ev := changes(old, new)
+	if want := []string{"/added", "/edited", "/removed"}; ev.All ||
+		!reflect.DeepEqual(ev.Paths, want) {
+		return fmt.Errorf("pages: expected %q, got %+v", want, ev)
+	}
+	if _, err := touch(newdir, "logo.png", "new png"); err != nil {
+		return err
+	}
+	if ev := changes(old, new); !ev.All || !contains(ev.Paths, "/logo.png") {
+		return fmt.Errorf("image: unexpected event %+v", ev)
+	}
+	return nil
+}
+
+// touch writes content to the file name in dir and returns its path.
+func TestCloseStreams(t *testing.T) {
+	if err := testCloseStreams(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testCloseStreams() error {
+	lh := &LiveHandler{}
+	srv := httptest.NewServer(lh)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + reloadPath)
+	if err != nil {
+		return fmt.Errorf("cannot open stream: %w", err)
+	}
+	defer resp.Body.Close()
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(resp.Body)
+		done <- err
+	}()
+	lh.CloseStreams()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("stream ended with %w", err)
+		}
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("stream not closed")
+	}
+	resp, err = http.Get(srv.URL + reloadPath)
+	if err != nil {
+		return fmt.Errorf("cannot open stream: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		return fmt.Errorf("stream opened after closing: %s", resp.Status)
+	}
+	return nil
+}
+
+func touch(dir, name, content string) (string, error) {
+	path := filepath.Join(dir, name)
+	return path, os.WriteFile(path, []byte(content), 0666)
+}
+
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,13 @@
 package sitefile
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/hylodoc/hyloblog-ssg/internal/assert"
@@ -10,27 +17,52 @@ type Resource interface {
 	Path() string
 	IsPost() bool
 	Post() Post
+
+	// The metadata below is set by Describe.
+	ContentType() string
+	Size() int64
+	Hash() string
+	ModTime() time.Time
+	URL() string
 }
 
 type Post interface {
 	Title() string
 	Time() (time.Time, bool)
+	Updated() (time.Time, bool)
 	HtmlPath() string
 	PlaintextPath() string
+	Authors() []Author
+	Tags() []string
+	Summary() string
+	URL() string
+}
+
+type Author struct {
+	Name, Email string
 }
 
 type file struct {
 	path   string
 	ispost bool
 	post   Post
+	meta   metadata
+}
+
+type metadata struct {
+	contenttype string
+	size        int64
+	hash        string
+	modtime     time.Time
+	url         string
 }
 
 func NewPostResource(path string, post Post) Resource {
-	return &file{path, true, post}
+	return &file{path, true, post, metadata{}}
 }
 
 func NewNonPostResource(path string) Resource {
-	return &file{path, false, nil}
+	return &file{path, false, nil, metadata{}}
 }
 
 func (f *file) Path() string { return f.path }
@@ -40,32 +72,75 @@ func (f *file) Post() Post {
 	return f.post
 }
 
-type post struct {
-	title                   string
-	htmlpath, plaintextpath string
-	time                    time.Time
-}
+func (f *file) ContentType() string { return f.meta.contenttype }
+func (f *file) Size() int64         { return f.meta.size }
+func (f *file) Hash() string        { return f.meta.hash }
+func (f *file) ModTime() time.Time  { return f.meta.modtime }
+func (f *file) URL() string         { return f.meta.url }
 
-func NewPost(title, htmlpath, plaintextpath string) *post {
-	return &post{title, htmlpath, plaintextpath, time.Time{}}
+// Describe returns a copy of rsc carrying the metadata of its generated file,
+// which is served at url.
+func Describe(rsc Resource, url string) (Resource, error) {
+	f, ok := rsc.(*file)
+	assert.Assert(ok)
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat: %w", err)
+	}
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	n := *f
+	n.meta = metadata{
+		contenttype: contenttype(f.path, b),
+		size:        int64(len(b)),
+		hash:        hex.EncodeToString(sum[:]),
+		modtime:     info.ModTime(),
+		url:         url,
+	}
+	return &n, nil
 }
 
-func NewTimedPost(title, htmlpath, plaintextpath string, time time.Time) *post {
-	return &post{title, htmlpath, plaintextpath, time}
+func contenttype(path string, content []byte) string {
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		return t
+	}
+	return http.DetectContentType(content)
 }
 
-func (p *post) Title() string {
-	return p.title
+// A PostData holds what is known of a post. Times are zero if unknown.
+type PostData struct {
+	Title                   string
+	HtmlPath, PlaintextPath string
+	Published, Updated      time.Time
+	Authors                 []Author
+	Tags                    []string
+	Summary                 string
+	URL                     string
 }
 
-func (p *post) HtmlPath() string {
-	return p.htmlpath
+type post struct {
+	d PostData
 }
 
-func (p *post) PlaintextPath() string {
-	return p.plaintextpath
+func NewPost(d PostData) Post {
+	return &post{d}
 }
 
+func (p *post) Title() string         { return p.d.Title }
+func (p *post) HtmlPath() string      { return p.d.HtmlPath }
+func (p *post) PlaintextPath() string { return p.d.PlaintextPath }
+func (p *post) Authors() []Author     { return p.d.Authors }
+func (p *post) Tags() []string        { return p.d.Tags }
+func (p *post) Summary() string       { return p.d.Summary }
+func (p *post) URL() string           { return p.d.URL }
+
 func (p *post) Time() (time.Time, bool) {
-	return p.time, !p.time.IsZero()
+	return p.d.Published, !p.d.Published.IsZero()
+}
+
+func (p *post) Updated() (time.Time, bool) {
+	return p.d.Updated, !p.d.Updated.IsZero()
 }
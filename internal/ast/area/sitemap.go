@@ -0,0 +1,144 @@
+package area
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/areainfo"
+)
+
+const (
+	sitemapFile = "sitemap.xml"
+	robotsFile  = "robots.txt"
+
+	defaultRobots = "User-agent: *\nAllow: /\n"
+)
+
+type sitemapentry struct {
+	url     string
+	lastmod time.Time
+}
+
+// sitemaphostpaths maps the host paths of the sitemap and robots.txt to the
+// paths on disk they are generated into. The sitemap requires absolute URLs,
+// so neither is generated unless a base URL is configured.
+func sitemaphostpaths(dir string, g *areainfo.GenInfo) map[string]string {
+	if g.BaseURL() == "" {
+		return map[string]string{}
+	}
+	return map[string]string{
+		"/" + sitemapFile: filepath.Join(dir, sitemapFile),
+		"/" + robotsFile:  filepath.Join(dir, robotsFile),
+	}
+}
+
+func (A *Area) generatesitemap(dir string, g *areainfo.GenInfo) error {
+	if g.BaseURL() == "" {
+		return nil
+	}
+	entries, err := A.sitemapentries(dir, g)
+	if err != nil {
+		return fmt.Errorf("cannot get entries: %w", err)
+	}
+	if err := writefile(
+		filepath.Join(dir, sitemapFile),
+		func(w io.Writer) error { return writesitemap(w, entries, g) },
+	); err != nil {
+		return fmt.Errorf("cannot write sitemap: %w", err)
+	}
+	if err := writefile(
+		filepath.Join(dir, robotsFile),
+		func(w io.Writer) error { return writerobots(w, g) },
+	); err != nil {
+		return fmt.Errorf("cannot write robots: %w", err)
+	}
+	return nil
+}
+
+func (A *Area) sitemapentries(
+	dir string, g *areainfo.GenInfo,
+) ([]sitemapentry, error) {
+	if index, ok := A.pages[indexFile]; ok {
+		g = g.WithNewIndex(index)
+	}
+	var entries []sitemapentry
+	for _, a := range A.subareas {
+		sub, err := a.sitemapentries(filepath.Join(dir, a.prefix), g)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"cannot get subarea %q entries: %w",
+				filepath.Join(dir, a.prefix), err,
+			)
+		}
+		entries = append(entries, sub...)
+	}
+	for name, pg := range A.pages {
+		path, err := pagehostpath(pg, name, dir, g)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"cannot make path for %q: %w", name, err,
+			)
+		}
+		if name == indexFile && !g.DynamicLinks() && path != "/" {
+			path += "/"
+		}
+		lastmod, _ := pg.Updated()
+		entries = append(entries, sitemapentry{path, lastmod})
+	}
+	return entries, nil
+}
+
+type urlset struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapurl `xml:"url"`
+}
+
+type sitemapurl struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+func writesitemap(
+	w io.Writer, entries []sitemapentry, g *areainfo.GenInfo,
+) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].url < entries[j].url
+	})
+	set := urlset{URLs: make([]sitemapurl, len(entries))}
+	for i, e := range entries {
+		set.URLs[i].Loc = g.BaseURL() + e.url
+		if !e.lastmod.IsZero() {
+			set.URLs[i].LastMod = e.lastmod.Format(time.RFC3339)
+		}
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(&set); err != nil {
+		return fmt.Errorf("cannot encode: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writerobots(w io.Writer, g *areainfo.GenInfo) error {
+	robots := g.Robots()
+	if robots == "" {
+		robots = defaultRobots
+	}
+	_, err := fmt.Fprintf(
+		w, "%s\nSitemap: %s/%s\n",
+		strings.TrimRight(robots, "\n"), g.BaseURL(), sitemapFile,
+	)
+	return err
+}
@@ -0,0 +1,107 @@
+package area
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/areainfo"
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/page"
+)
+
+// A tagfile is a generated tag listing page. The overview page has an empty
+// tag.
+type tagfile struct {
+	tag, url, path string
+}
+
+func (A *Area) isroot() bool { return A.prefix == "" }
+
+func (A *Area) tagfiles(
+	dir string, g *areainfo.GenInfo,
+) ([]tagfile, error) {
+	return A.checktagfiles(tagfiles(dir, A.getposts(dir, g), g))
+}
+
+// checktagfiles returns files unless they would overwrite a directory of the
+// same name in the source.
+func (A *Area) checktagfiles(files []tagfile) ([]tagfile, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	for _, a := range A.subareas {
+		if a.prefix == page.TagsDir {
+			return nil, fmt.Errorf(
+				"source has a %q directory", page.TagsDir,
+			)
+		}
+	}
+	return files, nil
+}
+
+func tagfiles(
+	dir string, posts []page.Post, g *areainfo.GenInfo,
+) []tagfile {
+	tags := page.Tags(posts)
+	if len(tags) == 0 {
+		return nil
+	}
+	if !g.Theme().HasTags() {
+		log.Println("warning: theme has no tag templates, skipping tags")
+		return nil
+	}
+	files := []tagfile{{
+		"",
+		page.TagsLink(g),
+		filepath.Join(dir, page.TagsDir, "index.html"),
+	}}
+	for _, tag := range tags {
+		files = append(files, tagfile{
+			tag,
+			page.TagLink(tag, g),
+			filepath.Join(dir, page.TagsDir, page.TagSlug(tag)+".html"),
+		})
+	}
+	return files
+}
+
+func (A *Area) generatetags(dir string, g *areainfo.GenInfo) error {
+	posts := A.getposts(dir, g)
+	files, err := A.checktagfiles(tagfiles(dir, posts, g))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Join(dir, page.TagsDir), 0777); err != nil {
+		return fmt.Errorf("cannot make dir: %w", err)
+	}
+	index, _ := g.GetIndex()
+	for _, tf := range files {
+		if err := writetagfile(tf, posts, g, index); err != nil {
+			return fmt.Errorf("cannot write %q: %w", tf.path, err)
+		}
+	}
+	return nil
+}
+
+func writetagfile(
+	tf tagfile, posts []page.Post, g *areainfo.GenInfo, index page.Page,
+) error {
+	return writefile(tf.path, func(w io.Writer) error {
+		return generatetag(w, tf, posts, g, index)
+	})
+}
+
+func generatetag(
+	w io.Writer,
+	tf tagfile, posts []page.Post, g *areainfo.GenInfo, index page.Page,
+) error {
+	if tf.tag == "" {
+		return page.GenerateTags(w, posts, g, index)
+	}
+	return page.GenerateTag(w, tf.tag, posts, g, index)
+}
@@ -9,15 +9,17 @@ import (
 type authoring struct {
 	_metaauthors []string
 	_metadefs    map[string]authordef
-	_gitauthor   string
+	_gitauthors  []theme.Author
 }
 
 func newAuthoring(authors []string, defs map[string]authordef) *authoring {
-	return &authoring{authors, defs, ""}
+	return &authoring{authors, defs, nil}
 }
 
-func (a *authoring) addgitauthor(author string) {
-	a._gitauthor = author
+// addgitauthors records the contributors to the page according to its git
+// history, in order of first contribution.
+func (a *authoring) addgitauthors(authors []theme.Author) {
+	a._gitauthors = authors
 }
 
 func (a *authoring) getauthors(index *authoring) []theme.Author {
@@ -35,8 +37,8 @@ func (a *authoring) getauthors(index *authoring) []theme.Author {
 
 func (a *authoring) getauthorsnoindex() []theme.Author {
 	if len(a._metaauthors) == 0 {
-		if len(a._gitauthor) > 0 {
-			return []theme.Author{theme.Author{Name: a._gitauthor}}
+		if len(a._gitauthors) > 0 {
+			return a._gitauthors
 		}
 		return []theme.Author{}
 	}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"time"
 
 	"github.com/hylodoc/hyloblog-ssg/internal/assert"
 	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/sitefile"
@@ -40,7 +41,13 @@ func (pg *custompage) GenerateIndex(
 }
 
 func (pg *custompage) GenerateWithoutIndex(w io.Writer, pi PageInfo) error {
-	return pi.Theme().ExecuteCustom(w, pg.template, pg.data)
+	return pi.Theme().ExecuteCustom(
+		w,
+		pg.template,
+		pg.datawithmoremap(map[string]string{
+			"SiteTitle": pi.SiteTitle(),
+		}),
+	)
 }
 
 func (pg *custompage) Generate(w io.Writer, pi PageInfo, index Page) error {
@@ -52,7 +59,7 @@ func (pg *custompage) Generate(w io.Writer, pi PageInfo, index Page) error {
 		w,
 		pg.template,
 		pg.datawithmoremap(map[string]string{
-			"SiteTitle": indexppg.title,
+			"SiteTitle": sitetitle(pi, indexppg),
 		}),
 	)
 }
@@ -76,13 +83,33 @@ func (pg *custompage) datawithmoremap(more map[string]string) map[string]string
 	return m
 }
 
-func (pg *custompage) IsPost() bool { return false }
+func (pg *custompage) IsPost() bool  { return false }
+func (pg *custompage) IsDraft() bool { return false }
+
+func (pg *custompage) Updated() (time.Time, bool) { return time.Time{}, false }
+
+func (pg *custompage) HasHistory() bool    { return false }
+func (pg *custompage) Revisions() []string { return nil }
+
+func (pg *custompage) GenerateHistory(
+	w io.Writer, pi PageInfo, index Page, links *HistoryLinks,
+) error {
+	return fmt.Errorf("custom page has no history")
+}
+
+func (pg *custompage) GenerateRevision(
+	w io.Writer, pi PageInfo, index Page, links *HistoryLinks, rev int,
+) error {
+	return fmt.Errorf("custom page has no history")
+}
 
 func (pg *custompage) AsPost(_, _ string) *Post {
 	assert.Assert(false)
 	return nil
 }
 
-func (pg *custompage) ToResource(path, _, _ string) (sitefile.Resource, error) {
+func (pg *custompage) ToResource(
+	path, _, _ string, _ PageInfo, _ Page, _ string,
+) (sitefile.Resource, error) {
 	return sitefile.NewNonPostResource(path), nil
 }
@@ -0,0 +1,36 @@
+package page
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// A SourceError is an error in the source of a page at Line, which is 0 if it
+// is not known.
+type SourceError struct {
+	Line int
+	Err  error
+}
+
+func (e *SourceError) Error() string {
+	if e.Line == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *SourceError) Unwrap() error { return e.Err }
+
+var yamlline = regexp.MustCompile(`line (\d+):`)
+
+// metadataerror locates the YAML error err in the source, given the line on
+// which the metadata starts.
+func metadataerror(err error, start int) *SourceError {
+	if m := yamlline.FindStringSubmatch(err.Error()); m != nil {
+		if n, e := strconv.Atoi(m[1]); e == nil {
+			return &SourceError{start + n - 1, err}
+		}
+	}
+	return &SourceError{start, err}
+}
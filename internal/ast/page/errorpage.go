@@ -0,0 +1,19 @@
+package page
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/theme"
+)
+
+// GenerateErrorPage writes the theme's page for the HTTP status.
+func GenerateErrorPage(w io.Writer, status int, pi PageInfo, index Page) error {
+	return pi.Theme().ExecuteErrorPage(w, &theme.ErrorData{
+		Title:     http.StatusText(status),
+		SiteTitle: sitetitle(pi, indexpage(index)),
+		Status:    status,
+		Head:      pi.Head(),
+		Foot:      pi.Foot(),
+	})
+}
@@ -0,0 +1,250 @@
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+type Feed struct {
+	Title, Description string
+
+	// Link is the URL of the page the feed belongs to, and Self the URL of
+	// the feed itself.
+	Link, Self string
+
+	Items []Item
+}
+
+type Item struct {
+	Title, Link, Content string
+	Published, Updated   time.Time
+	Authors              []Author
+}
+
+type Author struct {
+	Name, Email, Link string
+}
+
+func (f *Feed) updated() time.Time {
+	var t time.Time
+	for _, item := range f.Items {
+		if item.Updated.After(t) {
+			t = item.Updated
+		}
+	}
+	return t
+}
+
+func formattime(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(layout)
+}
+
+func writexml(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("cannot encode: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rsschannel `xml:"channel"`
+}
+
+type rsschannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          rsslink   `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssitem `xml:"item"`
+}
+
+type rsslink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssitem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Creators    []string `xml:"dc:creator"`
+	Description string   `xml:"description"`
+}
+
+// WriteRSS writes f as an RSS 2.0 document.
+func WriteRSS(w io.Writer, f *Feed) error {
+	items := make([]rssitem, len(f.Items))
+	for i, item := range f.Items {
+		var creators []string
+		for _, a := range item.Authors {
+			creators = append(creators, a.Name)
+		}
+		items[i] = rssitem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        item.Link,
+			PubDate:     formattime(item.Published, time.RFC1123Z),
+			Creators:    creators,
+			Description: item.Content,
+		}
+	}
+	return writexml(w, &rss{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rsschannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Description,
+			Self: rsslink{
+				f.Self, "self", "application/rss+xml",
+			},
+			LastBuildDate: formattime(f.updated(), time.RFC1123Z),
+			Items:         items,
+		},
+	})
+}
+
+type atom struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomlink  `xml:"link"`
+	Updated string      `xml:"updated,omitempty"`
+	Entries []atomentry `xml:"entry"`
+}
+
+type atomlink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomentry struct {
+	Title     string       `xml:"title"`
+	ID        string       `xml:"id"`
+	Link      atomlink     `xml:"link"`
+	Published string       `xml:"published,omitempty"`
+	Updated   string       `xml:"updated,omitempty"`
+	Authors   []atomauthor `xml:"author"`
+	Content   atomcontent  `xml:"content"`
+}
+
+type atomauthor struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+	URI   string `xml:"uri,omitempty"`
+}
+
+type atomcontent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// WriteAtom writes f as an Atom 1.0 document.
+func WriteAtom(w io.Writer, f *Feed) error {
+	entries := make([]atomentry, len(f.Items))
+	for i, item := range f.Items {
+		var authors []atomauthor
+		for _, a := range item.Authors {
+			authors = append(
+				authors, atomauthor{a.Name, a.Email, a.Link},
+			)
+		}
+		entries[i] = atomentry{
+			Title:     item.Title,
+			ID:        item.Link,
+			Link:      atomlink{Href: item.Link},
+			Published: formattime(item.Published, time.RFC3339),
+			Updated:   formattime(item.Updated, time.RFC3339),
+			Authors:   authors,
+			Content:   atomcontent{"html", item.Content},
+		}
+	}
+	return writexml(w, &atom{
+		Title: f.Title,
+		ID:    f.Link,
+		Links: []atomlink{
+			{Href: f.Link, Rel: "alternate"},
+			{Href: f.Self, Rel: "self"},
+		},
+		Updated: formattime(f.updated(), time.RFC3339),
+		Entries: entries,
+	})
+}
+
+type jsonfeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonfeeditem `json:"items"`
+}
+
+type jsonfeeditem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url,omitempty"`
+	Title         string           `json:"title,omitempty"`
+	ContentHTML   string           `json:"content_html"`
+	DatePublished string           `json:"date_published,omitempty"`
+	DateModified  string           `json:"date_modified,omitempty"`
+	Authors       []jsonfeedauthor `json:"authors,omitempty"`
+}
+
+type jsonfeedauthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+// WriteJSON writes f as a JSON Feed 1.1 document.
+func WriteJSON(w io.Writer, f *Feed) error {
+	items := make([]jsonfeeditem, len(f.Items))
+	for i, item := range f.Items {
+		var authors []jsonfeedauthor
+		for _, a := range item.Authors {
+			authors = append(authors, jsonfeedauthor{a.Name, a.Link})
+		}
+		items[i] = jsonfeeditem{
+			ID:            item.Link,
+			URL:           item.Link,
+			Title:         item.Title,
+			ContentHTML:   item.Content,
+			DatePublished: formattime(item.Published, time.RFC3339),
+			DateModified:  formattime(item.Updated, time.RFC3339),
+			Authors:       authors,
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&jsonfeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.Self,
+		Description: f.Description,
+		Items:       items,
+	})
+}
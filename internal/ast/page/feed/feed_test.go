@@ -0,0 +1,86 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFeeds(t *testing.T) {
+	if err := testFeeds(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testFeeds() error {
+	published := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	f := &Feed{
+		Title: "blog",
+		Link:  "https://example.com/",
+		Self:  "https://example.com/feed.xml",
+		Items: []Item{{
+			Title:     "post",
+			Link:      "https://example.com/post",
+			Content:   "<p>a & b</p>",
+			Published: published,
+			Updated:   published.Add(time.Hour),
+			Authors:   []Author{{Name: "Ann"}},
+		}},
+	}
+
+	var rssbuf bytes.Buffer
+	if err := WriteRSS(&rssbuf, f); err != nil {
+		return fmt.Errorf("rss: %w", err)
+	}
+	var r struct {
+		Items []struct {
+			Description string `xml:"description"`
+			PubDate     string `xml:"pubDate"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(rssbuf.Bytes(), &r); err != nil {
+		return fmt.Errorf("rss unmarshal: %w", err)
+	}
+	if len(r.Items) != 1 || r.Items[0].Description != "<p>a & b</p>" {
+		return fmt.Errorf("rss: unexpected items %+v", r.Items)
+	}
+	if r.Items[0].PubDate != published.Format(time.RFC1123Z) {
+		return fmt.Errorf("rss: unexpected date %q", r.Items[0].PubDate)
+	}
+
+	var atombuf bytes.Buffer
+	if err := WriteAtom(&atombuf, f); err != nil {
+		return fmt.Errorf("atom: %w", err)
+	}
+	var a struct {
+		Updated string `xml:"updated"`
+	}
+	if err := xml.Unmarshal(atombuf.Bytes(), &a); err != nil {
+		return fmt.Errorf("atom unmarshal: %w", err)
+	}
+	if want := "2024-01-02T04:04:05Z"; a.Updated != want {
+		return fmt.Errorf("atom: updated %q, want %q", a.Updated, want)
+	}
+
+	var jsonbuf bytes.Buffer
+	if err := WriteJSON(&jsonbuf, f); err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+	var j struct {
+		Items []struct {
+			Authors []struct {
+				Name string `json:"name"`
+			} `json:"authors"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(jsonbuf.Bytes(), &j); err != nil {
+		return fmt.Errorf("json unmarshal: %w", err)
+	}
+	if len(j.Items) != 1 || len(j.Items[0].Authors) != 1 {
+		return fmt.Errorf("json: unexpected items %+v", j.Items)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package page
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/page/feed"
+	"github.com/hylodoc/hyloblog-ssg/internal/theme"
+)
+
+type FeedFormat int
+
+const (
+	FeedRSS FeedFormat = iota
+	FeedAtom
+	FeedJSON
+)
+
+// GenerateFeed writes the posts of the area with the given index in format.
+// The link and self arguments are the host paths of the area and the feed,
+// which are made absolute using the base URL when one is configured.
+func GenerateFeed(
+	w io.Writer, format FeedFormat,
+	posts []Post, pi PageInfo, index Page,
+	link, self string,
+) error {
+	f := tofeed(posts, pi, indexpage(index), link, self)
+	switch format {
+	case FeedRSS:
+		return feed.WriteRSS(w, f)
+	case FeedAtom:
+		return feed.WriteAtom(w, f)
+	case FeedJSON:
+		return feed.WriteJSON(w, f)
+	default:
+		return fmt.Errorf("unknown feed format %d", format)
+	}
+}
+
+func tofeed(
+	posts []Post, pi PageInfo, index *parsedpage, link, self string,
+) *feed.Feed {
+	sortposts(posts)
+	items := make([]feed.Item, len(posts))
+	for i, p := range posts {
+		items[i] = feed.Item{
+			Title:   p.title,
+			Link:    absurl(pi, p.link),
+			Content: p.content,
+			Authors: tofeedauthors(p.a.getauthors(&index.a), pi),
+		}
+		if p.timing != nil {
+			items[i].Published = p.timing.published
+			items[i].Updated = p.timing.updated
+		}
+	}
+	return &feed.Feed{
+		Title:       sitetitle(pi, index),
+		Description: summarize(index.rawmd),
+		Link:        absurl(pi, link),
+		Self:        absurl(pi, self),
+		Items:       items,
+	}
+}
+
+func tofeedauthors(authors []theme.Author, pi PageInfo) []feed.Author {
+	feedauthors := make([]feed.Author, len(authors))
+	for i, a := range authors {
+		feedauthors[i] = feed.Author{
+			Name:  a.Name,
+			Email: a.Email,
+			Link:  absurl(pi, a.Page),
+		}
+	}
+	return feedauthors
+}
+
+// absurl prefixes host paths with the base URL, leaving full URLs (and empty
+// strings) unchanged.
+func absurl(pi PageInfo, u string) string {
+	if len(u) == 0 || u[0] != '/' {
+		return u
+	}
+	return pi.BaseURL() + u
+}
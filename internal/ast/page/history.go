@@ -0,0 +1,115 @@
+package page
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/gitindex"
+	"github.com/hylodoc/hyloblog-ssg/internal/theme"
+)
+
+// A githistory is the record of the commits that touched a page.
+type githistory struct {
+	git     *gitindex.Index
+	commits []gitindex.Commit
+}
+
+// newgithistory returns the history of a page with commits ordered newest
+// first by commit time.
+func newgithistory(
+	git *gitindex.Index, commits []gitindex.Commit,
+) *githistory {
+	sorted := append([]gitindex.Commit{}, commits...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Committer.When.After(sorted[j].Committer.When)
+	})
+	return &githistory{git, sorted}
+}
+
+// HistoryLinks are the URLs of a page, its history and each of its revisions
+// in the order returned by Revisions.
+type HistoryLinks struct {
+	Page, History string
+	Revisions     []string
+}
+
+func (pg *parsedpage) HasHistory() bool {
+	return pg.history != nil && len(pg.history.commits) > 0
+}
+
+func (pg *parsedpage) Revisions() []string {
+	if pg.history == nil {
+		return nil
+	}
+	hashes := make([]string, len(pg.history.commits))
+	for i, c := range pg.history.commits {
+		hashes[i] = c.Hash
+	}
+	return hashes
+}
+
+func (pg *parsedpage) GenerateHistory(
+	w io.Writer, pi PageInfo, index Page, links *HistoryLinks,
+) error {
+	return pi.Theme().ExecuteHistory(w, &theme.HistoryData{
+		Title:     pg.title,
+		SiteTitle: sitetitle(pi, indexpage(index)),
+		PostLink:  links.Page,
+		Revisions: pg.revisions(pi, links),
+		Head:      pi.Head(),
+		Foot:      pi.Foot(),
+	})
+}
+
+func (pg *parsedpage) GenerateRevision(
+	w io.Writer, pi PageInfo, index Page, links *HistoryLinks, rev int,
+) error {
+	diff, err := pg.history.git.Diff(pg.history.commits[rev])
+	if err != nil {
+		return fmt.Errorf("cannot get diff: %w", err)
+	}
+	return pi.Theme().ExecuteRevision(w, &theme.RevisionData{
+		Title:       pg.title,
+		SiteTitle:   sitetitle(pi, indexpage(index)),
+		PostLink:    links.Page,
+		HistoryLink: links.History,
+		Revision:    pg.revisions(pi, links)[rev],
+		Diff:        diff,
+		Head:        pi.Head(),
+		Foot:        pi.Foot(),
+	})
+}
+
+func (pg *parsedpage) revisions(
+	pi PageInfo, links *HistoryLinks,
+) []theme.Revision {
+	revs := make([]theme.Revision, len(pg.history.commits))
+	for i, c := range pg.history.commits {
+		var authors []theme.Author
+		for _, p := range pg.history.git.CommitAuthors(c) {
+			authors = append(
+				authors, theme.Author{Name: p.Name, Email: p.Email},
+			)
+		}
+		revs[i] = theme.Revision{
+			Hash:      c.Hash,
+			ShortHash: ShortHash(c.Hash),
+			Date:      c.Author.When.Format(pi.DateFormat()),
+			Subject:   c.Subject(),
+			Message:   strings.TrimSpace(c.Message),
+			Authors:   authors,
+			Link:      links.Revisions[i],
+		}
+	}
+	return revs
+}
+
+// ShortHash abbreviates a commit hash as git does by default.
+func ShortHash(hash string) string {
+	if len(hash) < 7 {
+		return hash
+	}
+	return hash[:7]
+}
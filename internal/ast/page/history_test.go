@@ -0,0 +1,42 @@
+package page
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/gitindex"
+)
+
+func TestHistoryOrder(t *testing.T) {
+	if err := testHistoryOrder(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testHistoryOrder() error {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var commits []gitindex.Commit
+	for _, c := range []struct {
+		hash string
+		days int
+	}{
+		{"a", 1},
+		{"b", 0},
+		{"c", 2},
+	} {
+		commits = append(commits, gitindex.Commit{
+			Hash:      c.hash,
+			Committer: gitindex.Signature{When: day.AddDate(0, 0, c.days)},
+		})
+	}
+	h := newgithistory(nil, commits)
+	var hashes []string
+	for _, c := range h.commits {
+		hashes = append(hashes, c.Hash)
+	}
+	if fmt.Sprint(hashes) != "[c a b]" {
+		return fmt.Errorf("expected [c a b], got %v", hashes)
+	}
+	return nil
+}
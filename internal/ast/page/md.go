@@ -1,12 +1,14 @@
 package page
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
 	katex "github.com/FurqanSoftware/goldmark-katex"
 	"github.com/alecthomas/chroma"
 	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/hylodoc/hyloblog-ssg/internal/cache"
 	"github.com/yuin/goldmark"
 	hl "github.com/yuin/goldmark-highlighting"
 	"github.com/yuin/goldmark/ast"
@@ -22,6 +24,31 @@ type mdpage struct {
 	title, content string
 }
 
+// rendermdpage is parsemdpage with the result stored in c. The key needn't
+// include the theme, because the result is the body of the page before any
+// template is applied to it.
+func rendermdpage(content, style string, c *cache.Cache) (*mdpage, error) {
+	b, err := c.Do(cache.Key("md", style, content), func() ([]byte, error) {
+		pg, err := parsemdpage(content, style)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(cachedmdpage{pg.title, pg.content})
+	})
+	if err != nil {
+		return nil, err
+	}
+	var cached cachedmdpage
+	if err := json.Unmarshal(b, &cached); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal cached page: %w", err)
+	}
+	return &mdpage{cached.Title, cached.Content}, nil
+}
+
+type cachedmdpage struct {
+	Title, Content string
+}
+
 func parsemdpage(content, style string) (*mdpage, error) {
 	g := goldmark.New(
 		goldmark.WithParserOptions(
@@ -101,3 +128,50 @@ func gettitle(doc gm_ast.Node, content string) string {
 	})
 	return title
 }
+
+// summaryLength is the most runes a summary has before it is cut short.
+const summaryLength = 280
+
+// summarize returns the text of the first paragraph of the Markdown content,
+// cut at a word boundary if it is too long.
+func summarize(content string) string {
+	src := []byte(content)
+	doc := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+	).Parser().Parse(text.NewReader(src))
+	var b strings.Builder
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if _, ok := n.(*ast.Paragraph); ok {
+				return ast.WalkStop, nil
+			}
+			return ast.WalkContinue, nil
+		}
+		if _, ok := n.(*ast.Heading); ok {
+			return ast.WalkSkipChildren, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			b.Write(n.Segment.Value(src))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(n.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return truncate(strings.TrimSpace(b.String()), summaryLength)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	cut := string(r[:n])
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:.") + "…"
+}
@@ -15,6 +15,8 @@ type metadata struct {
 	Author      []string             `yaml:"author"`
 	AuthorDefs  map[string]authordef `yaml:"authors"`
 	ChromaStyle string               `yaml:"chroma"`
+	Draft       bool                 `yaml:"draft"`
+	Tags        []string             `yaml:"tags"`
 }
 
 func parsemetadata(raw string) (*metadata, error) {
@@ -61,8 +63,9 @@ func (m *metadata) authoring() *authoring {
 }
 
 type authordef struct {
-	Name string `yaml:"name"`
-	Page string `yaml:"page"`
+	Name  string `yaml:"name"`
+	Page  string `yaml:"page"`
+	Email string `yaml:"email"`
 }
 
 func tostrings(defs []authordef) []string {
@@ -2,6 +2,7 @@ package page
 
 import (
 	"io"
+	"time"
 
 	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/sitefile"
 	"github.com/hylodoc/hyloblog-ssg/internal/theme"
@@ -18,10 +19,22 @@ type Page interface {
 	GenerateEmailText(w io.Writer) error
 
 	IsPost() bool
+	IsDraft() bool
+	Updated() (time.Time, bool)
+
+	HasHistory() bool
+	Revisions() []string
+	GenerateHistory(
+		w io.Writer, pi PageInfo, index Page, links *HistoryLinks,
+	) error
+	GenerateRevision(
+		w io.Writer, pi PageInfo, index Page, links *HistoryLinks, rev int,
+	) error
 	AsPost(category, link string) *Post
 
 	ToResource(
 		pagepath, emailhtmlpath, emailtextpath string,
+		pi PageInfo, index Page, link string,
 	) (sitefile.Resource, error)
 }
 
@@ -29,6 +42,11 @@ type PageInfo interface {
 	Theme() *theme.Theme
 	Head() string
 	Foot() string
+	SiteTitle() string
+	DateFormat() string
+	HistoryLink() string
+	PathPrefix() string
 	Root() string
+	BaseURL() string
 	DynamicLinks() bool
 }
@@ -1,20 +1,21 @@
 package page
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
+	"unicode"
 
-	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/hylodoc/hyloblog-ssg/internal/assert"
 	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/sitefile"
 	"github.com/hylodoc/hyloblog-ssg/internal/ast/page/pandoc"
+	"github.com/hylodoc/hyloblog-ssg/internal/cache"
+	"github.com/hylodoc/hyloblog-ssg/internal/gitindex"
 	"github.com/hylodoc/hyloblog-ssg/internal/theme"
 )
 
@@ -24,24 +25,29 @@ type parsedpage struct {
 	doc        string
 	rawmd      string
 	a          authoring
+	draft      bool
+	tags       []string
+	history    *githistory
+	cache      *cache.Cache
 }
 
-func ParsePage(path, chromastyle string) (Page, error) {
-	buf, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("cannot read file: %w", err)
-	}
+// ParsePage parses the Markdown source of a page in buf. Rendering results are
+// taken from c where possible.
+func ParsePage(buf []byte, chromastyle string, c *cache.Cache) (Page, error) {
 	components, err := separate(string(buf))
 	if err != nil {
 		return nil, fmt.Errorf("cannot separate: %w", err)
 	}
-	mdpage, err := parsemdpage(components.content, chromastyle)
+	mdpage, err := rendermdpage(components.content, chromastyle, c)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse content: %w", err)
 	}
 	m, err := parsemetadata(components.metadata)
 	if err != nil {
-		return nil, fmt.Errorf("cannot parse metadata: %w", err)
+		return nil, fmt.Errorf(
+			"cannot parse metadata: %w",
+			metadataerror(err, components.metaline),
+		)
 	}
 	return &parsedpage{
 		title:  mdpage.title,
@@ -50,30 +56,42 @@ func ParsePage(path, chromastyle string) (Page, error) {
 		doc:    mdpage.content,
 		rawmd:  components.content,
 		a:      *m.authoring(),
+		draft:  m.Draft,
+		tags:   m.Tags,
+		cache:  c,
 	}, nil
 }
 
 type components struct {
 	metadata string
 	content  string
+	metaline int
 }
 
 func separate(s string) (*components, error) {
+	line := 1 + strings.Count(leadingspace(s), "\n")
 	s = strings.TrimSpace(s)
 	if len(s) < 3 || s[:3] != "---" {
-		return &components{"", s}, nil
+		return &components{"", s, 0}, nil
 	}
 	s = s[3:]
 	endmeta := strings.Index(s, "---")
 	if endmeta == -1 {
-		return nil, fmt.Errorf("unclosed metadata section")
+		return nil, &SourceError{
+			line, fmt.Errorf("unclosed metadata section"),
+		}
 	}
 	return &components{
 		strings.TrimSpace(s[:endmeta]),
 		strings.TrimSpace(s[endmeta+3:]),
+		line + strings.Count(leadingspace(s[:endmeta]), "\n"),
 	}, nil
 }
 
+func leadingspace(s string) string {
+	return s[:len(s)-len(strings.TrimLeftFunc(s, unicode.IsSpace))]
+}
+
 func (p *parsedpage) Title() (string, error) {
 	return p.title, nil
 }
@@ -82,7 +100,7 @@ func (pg *parsedpage) Link(path string, pi PageInfo) (string, error) {
 	dynamiclinks := pi.DynamicLinks()
 	if pg.url != "" {
 		if dynamiclinks {
-			return pg.url, nil
+			return pi.PathPrefix() + pg.url, nil
 		}
 		log.Printf("warning: %q has custom url in static mode\n", path)
 	}
@@ -109,14 +127,19 @@ func replaceext(path, newext string) string {
 	return path[:len(path)-len(ext)] + newext
 }
 
-func ParsePageGit(path, gitdir, chromastyle string) (Page, error) {
-	pg, err := ParsePage(path, chromastyle)
+// ParsePageGit parses the page at path, whose source is in buf, taking its
+// timing and authors from git where the metadata doesn't give them.
+func ParsePageGit(
+	path string, buf []byte, git *gitindex.Index, chromastyle string,
+	c *cache.Cache,
+) (Page, error) {
+	pg, err := ParsePage(buf, chromastyle, c)
 	if err != nil {
 		return nil, err
 	}
 	ppg, ok := pg.(*parsedpage)
 	assert.Assert(ok)
-	info, err := getgitinfo(path, gitdir)
+	info, err := getgitinfo(path, git)
 	if err != nil {
 		return nil, fmt.Errorf(
 			"cannot get timing from git: %w", err,
@@ -125,60 +148,42 @@ func ParsePageGit(path, gitdir, chromastyle string) (Page, error) {
 	if ppg.timing == nil {
 		ppg.timing = &info.timing
 	}
-	ppg.a.addgitauthor(info.author)
+	ppg.a.addgitauthors(info.authors)
+	ppg.history = newgithistory(git, info.commits)
 	return ppg, nil
 }
 
 type gitinfo struct {
-	timing timing
-	author string
+	timing  timing
+	authors []theme.Author
+	commits []gitindex.Commit
 }
 
 type timing struct {
 	published, updated time.Time
 }
 
-func getgitinfo(path, gitdir string) (*gitinfo, error) {
-	repo, err := git.PlainOpen(gitdir)
-	if err != nil {
-		return nil, fmt.Errorf("cannot open repo: %w", err)
-	}
-	rel, err := filepath.Rel(filepath.Join(gitdir, ".."), path)
-	if err != nil {
-		return nil, fmt.Errorf("cannot get relative path: %w", err)
-	}
-	log, err := repo.Log(&git.LogOptions{FileName: &rel})
+func getgitinfo(path string, git *gitindex.Index) (*gitinfo, error) {
+	commits, err := git.Commits(path)
 	if err != nil {
-		return nil, fmt.Errorf("cannot get log: %w", err)
+		return nil, fmt.Errorf("cannot get commits: %w", err)
 	}
-	defer log.Close()
-	commits := getcommits(log)
 	published, err := getcreated(commits)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get created: %w", err)
 	}
-	author, err := getauthor(commits)
+	authors, err := getgitauthors(path, git)
 	if err != nil {
-		return nil, fmt.Errorf("cannot get author: %w", err)
+		return nil, fmt.Errorf("cannot get authors: %w", err)
 	}
 	updated, err := getupdated(commits)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get updated: %w", err)
 	}
-	return &gitinfo{timing{*published, *updated}, author}, nil
+	return &gitinfo{timing{*published, *updated}, authors, commits}, nil
 }
 
-func getcommits(iter object.CommitIter) []object.Commit {
-	var commits []object.Commit
-	err := iter.ForEach(func(c *object.Commit) error {
-		commits = append(commits, *c)
-		return nil
-	})
-	assert.Assert(err == nil)
-	return commits
-}
-
-func getcreated(commits []object.Commit) (*time.Time, error) {
+func getcreated(commits []gitindex.Commit) (*time.Time, error) {
 	var t time.Time
 	for _, c := range commits {
 		when := c.Author.When
@@ -189,14 +194,19 @@ func getcreated(commits []object.Commit) (*time.Time, error) {
 	return &t, nil
 }
 
-func getauthor(commits []object.Commit) (string, error) {
-	if len(commits) == 0 {
-		return "", nil
+func getgitauthors(path string, git *gitindex.Index) ([]theme.Author, error) {
+	people, err := git.Authors(path)
+	if err != nil {
+		return nil, err
+	}
+	authors := make([]theme.Author, len(people))
+	for i, p := range people {
+		authors[i] = theme.Author{Name: p.Name, Email: p.Email}
 	}
-	return commits[0].Author.Name, nil
+	return authors, nil
 }
 
-func getupdated(commits []object.Commit) (*time.Time, error) {
+func getupdated(commits []gitindex.Commit) (*time.Time, error) {
 	var t time.Time
 	for _, c := range commits {
 		when := c.Author.When
@@ -209,11 +219,12 @@ func getupdated(commits []object.Commit) (*time.Time, error) {
 
 func (pg *parsedpage) GenerateIndex(w io.Writer, posts []Post, pi PageInfo) error {
 	return pi.Theme().ExecuteIndex(w, &theme.IndexData{
-		Title:   pg.title,
-		Content: pg.doc,
-		Posts:   tothemeposts(posts, pg),
-		Head:    pi.Head(),
-		Foot:    pi.Foot(),
+		Title:     pg.title,
+		Content:   pg.doc,
+		SiteTitle: sitetitle(pi, pg),
+		Posts:     tothemeposts(posts, pg, pi),
+		Head:      pi.Head(),
+		Foot:      pi.Foot(),
 	})
 }
 
@@ -221,49 +232,78 @@ type Post struct {
 	title, category, link string
 	timing                *timing
 	a                     authoring
+	draft                 bool
+	tags                  []string
+	content               string
 }
 
-func tothemeposts(posts []Post, index *parsedpage) []theme.Post {
-	sort.Slice(posts, func(i, j int) bool {
+// sortposts orders posts from the most to the least recently published. Posts
+// published at the same time keep their order.
+func sortposts(posts []Post) {
+	sort.SliceStable(posts, func(i, j int) bool {
 		t0, t1 := posts[i].timing, posts[j].timing
 		return t0 != nil && t1 != nil &&
 			t0.published.After(t1.published)
 	})
+}
+
+func tothemeposts(
+	posts []Post, index *parsedpage, pi PageInfo,
+) []theme.Post {
+	sortposts(posts)
 	themeposts := make([]theme.Post, len(posts))
 	for i, p := range posts {
 		themeposts[i] = theme.Post{
 			Title:    p.title,
 			Category: p.category,
 			Link:     p.link,
-			Date:     getdate(p.timing),
+			Date:     getdate(p.timing, pi),
 			Authors:  p.a.getauthors(&index.a),
+			Tags:     tothemetags(p.tags, pi),
+			Draft:    p.draft,
 		}
 	}
 	return themeposts
 }
 
-func (pg *parsedpage) IsPost() bool { return true }
+func (pg *parsedpage) IsPost() bool  { return true }
+func (pg *parsedpage) IsDraft() bool { return pg.draft }
 
 func (pg *parsedpage) AsPost(category, link string) *Post {
-	return &Post{pg.title, category, link, pg.timing, pg.a}
+	return &Post{
+		pg.title, category, link, pg.timing, pg.a, pg.draft, pg.tags,
+		pg.doc,
+	}
 }
 
 func (pg *parsedpage) GenerateWithoutIndex(w io.Writer, pi PageInfo) error {
 	return pi.Theme().ExecuteDefault(w, &theme.DefaultData{
-		Title:   pg.title,
-		Content: pg.doc,
-		Date:    getdate(pg.timing),
-		Authors: pg.a.getauthorsnoindex(),
-		Head:    pi.Head(),
-		Foot:    pi.Foot(),
+		Title:       pg.title,
+		Content:     pg.doc,
+		Date:        getdate(pg.timing, pi),
+		Authors:     pg.a.getauthorsnoindex(),
+		Tags:        tothemetags(pg.tags, pi),
+		Draft:       pg.draft,
+		HistoryLink: pi.HistoryLink(),
+		Head:        pi.Head(),
+		Foot:        pi.Foot(),
 	})
 }
 
-func getdate(t *timing) string {
+func getdate(t *timing, pi PageInfo) string {
 	if t == nil || t.published.IsZero() {
 		return ""
 	}
-	return t.published.Format("Jan 02, 2006")
+	return t.published.Format(pi.DateFormat())
+}
+
+// sitetitle is the configured title of the site, if any, and otherwise that of
+// the index.
+func sitetitle(pi PageInfo, index *parsedpage) string {
+	if title := pi.SiteTitle(); title != "" {
+		return title
+	}
+	return index.title
 }
 
 func (pg *parsedpage) time() (time.Time, bool) {
@@ -274,21 +314,41 @@ func (pg *parsedpage) time() (time.Time, bool) {
 	return t.published, true
 }
 
+func (pg *parsedpage) Updated() (time.Time, bool) {
+	t := pg.timing
+	if t == nil || t.updated.IsZero() {
+		return time.Time{}, false
+	}
+	return t.updated, true
+}
+
 func (pg *parsedpage) ToResource(
 	pagepath, emailhtmlpath, emailtextpath string,
+	pi PageInfo, index Page, link string,
 ) (sitefile.Resource, error) {
-	if time, ok := pg.time(); ok {
-		return sitefile.NewPostResource(
-			pagepath,
-			sitefile.NewTimedPost(
-				pg.title, emailhtmlpath, emailtextpath, time,
-			),
-		), nil
+	d := sitefile.PostData{
+		Title:         pg.title,
+		HtmlPath:      emailhtmlpath,
+		PlaintextPath: emailtextpath,
+		Authors: tositefileauthors(
+			pg.a.getauthors(&indexpage(index).a),
+		),
+		Tags:    pg.tags,
+		Summary: summarize(pg.rawmd),
+		URL:     absurl(pi, link),
+	}
+	if pg.timing != nil {
+		d.Published, d.Updated = pg.timing.published, pg.timing.updated
 	}
-	return sitefile.NewPostResource(
-		pagepath,
-		sitefile.NewPost(pg.title, emailhtmlpath, emailtextpath),
-	), nil
+	return sitefile.NewPostResource(pagepath, sitefile.NewPost(d)), nil
+}
+
+func tositefileauthors(authors []theme.Author) []sitefile.Author {
+	res := make([]sitefile.Author, len(authors))
+	for i, a := range authors {
+		res[i] = sitefile.Author{Name: a.Name, Email: a.Email}
+	}
+	return res
 }
 
 func (pg *parsedpage) GenerateEmailHtml(
@@ -298,7 +358,7 @@ func (pg *parsedpage) GenerateEmailHtml(
 		w, &theme.DefaultData{
 			Title:   pg.title,
 			Content: pg.doc,
-			Date:    getdate(pg.timing),
+			Date:    getdate(pg.timing, pi),
 			Authors: pg.a.getauthorsnoindex(),
 			Head:    "",
 			Foot:    "",
@@ -310,7 +370,19 @@ func (pg *parsedpage) GenerateEmailHtml(
 }
 
 func (pg *parsedpage) GenerateEmailText(w io.Writer) error {
-	return pandoc.ConvertPlaintext(pg.rawmd, w)
+	b, err := pg.cache.Do(
+		cache.Key("plain", pg.rawmd),
+		func() ([]byte, error) {
+			var buf bytes.Buffer
+			err := pandoc.ConvertPlaintext(pg.rawmd, &buf)
+			return buf.Bytes(), err
+		},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
 }
 
 func (pg *parsedpage) Generate(w io.Writer, pi PageInfo, index Page) error {
@@ -319,12 +391,15 @@ func (pg *parsedpage) Generate(w io.Writer, pi PageInfo, index Page) error {
 	assert.Assert(ok)
 
 	return pi.Theme().ExecuteDefault(w, &theme.DefaultData{
-		Title:     pg.title,
-		Content:   pg.doc,
-		SiteTitle: indexppg.title,
-		Date:      getdate(pg.timing),
-		Authors:   pg.a.getauthors(&indexppg.a),
-		Head:      pi.Head(),
-		Foot:      pi.Foot(),
+		Title:       pg.title,
+		Content:     pg.doc,
+		SiteTitle:   sitetitle(pi, indexppg),
+		Date:        getdate(pg.timing, pi),
+		Authors:     pg.a.getauthors(&indexppg.a),
+		Tags:        tothemetags(pg.tags, pi),
+		Draft:       pg.draft,
+		HistoryLink: pi.HistoryLink(),
+		Head:        pi.Head(),
+		Foot:        pi.Foot(),
 	})
 }
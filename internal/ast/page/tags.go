@@ -0,0 +1,152 @@
+package page
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/assert"
+	"github.com/hylodoc/hyloblog-ssg/internal/theme"
+	"golang.org/x/text/unicode/norm"
+)
+
+// TagsDir is the directory of the site root that tag pages are generated into.
+const TagsDir = "tags"
+
+// TagSlug is the name of the file (without extension) that the listing page
+// for tag is generated into. It is made of lowercase ASCII letters, digits and
+// hyphens, so that it needn't be escaped in URLs. Accents are dropped, and a
+// tag with other letters that cannot be spelled this way gets a suffix derived
+// from it to keep it apart from similar tags.
+func TagSlug(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	var b strings.Builder
+	dropped, hyphen := false, false
+	for _, r := range norm.NFD.String(tag) {
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9':
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		case unicode.Is(unicode.Mn, r):
+		default:
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				dropped = true
+			}
+			hyphen = true
+		}
+	}
+	if !dropped {
+		return b.String()
+	}
+	sum := sha256.Sum256([]byte(tag))
+	if b.Len() > 0 {
+		b.WriteByte('-')
+	}
+	b.WriteString(hex.EncodeToString(sum[:4]))
+	return b.String()
+}
+
+// TagsLink is the URL of the tag overview page.
+func TagsLink(pi PageInfo) string {
+	if pi.DynamicLinks() {
+		return pi.PathPrefix() + "/" + TagsDir
+	}
+	return pi.PathPrefix() + "/" + TagsDir + "/"
+}
+
+// TagLink is the URL of the listing page for tag.
+func TagLink(tag string, pi PageInfo) string {
+	return pi.PathPrefix() + "/" + TagsDir + "/" + TagSlug(tag) +
+		rightext(pi.DynamicLinks())
+}
+
+func tothemetags(tags []string, pi PageInfo) []theme.Tag {
+	themetags := make([]theme.Tag, len(tags))
+	for i, tag := range tags {
+		themetags[i] = theme.Tag{Name: tag, Link: TagLink(tag, pi)}
+	}
+	return themetags
+}
+
+// Tags returns the distinct tags of posts sorted by slug. Tags sharing a slug
+// are considered equal, and the first spelling encountered is kept.
+func Tags(posts []Post) []string {
+	seen := map[string]string{}
+	for _, p := range posts {
+		for _, tag := range p.tags {
+			slug := TagSlug(tag)
+			if _, ok := seen[slug]; !ok {
+				seen[slug] = tag
+			}
+		}
+	}
+	slugs := make([]string, 0, len(seen))
+	for slug := range seen {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	tags := make([]string, len(slugs))
+	for i, slug := range slugs {
+		tags[i] = seen[slug]
+	}
+	return tags
+}
+
+func withtag(posts []Post, tag string) []Post {
+	slug := TagSlug(tag)
+	var tagged []Post
+	for _, p := range posts {
+		for _, t := range p.tags {
+			if TagSlug(t) == slug {
+				tagged = append(tagged, p)
+				break
+			}
+		}
+	}
+	return tagged
+}
+
+func GenerateTags(w io.Writer, posts []Post, pi PageInfo, index Page) error {
+	var tags []theme.TagCount
+	for _, tag := range Tags(posts) {
+		tags = append(tags, theme.TagCount{
+			Tag:   theme.Tag{Name: tag, Link: TagLink(tag, pi)},
+			Count: len(withtag(posts, tag)),
+		})
+	}
+	return pi.Theme().ExecuteTags(w, &theme.TagsData{
+		Title:     "Tags",
+		SiteTitle: sitetitle(pi, indexpage(index)),
+		Tags:      tags,
+		Head:      pi.Head(),
+		Foot:      pi.Foot(),
+	})
+}
+
+func GenerateTag(
+	w io.Writer, tag string, posts []Post, pi PageInfo, index Page,
+) error {
+	return pi.Theme().ExecuteTag(w, &theme.TagData{
+		Title:     tag,
+		SiteTitle: sitetitle(pi, indexpage(index)),
+		Tag:       theme.Tag{Name: tag, Link: TagLink(tag, pi)},
+		Posts:     tothemeposts(withtag(posts, tag), indexpage(index), pi),
+		Head:      pi.Head(),
+		Foot:      pi.Foot(),
+	})
+}
+
+func indexpage(index Page) *parsedpage {
+	if index == nil {
+		return &parsedpage{}
+	}
+	ppg, ok := index.(*parsedpage)
+	assert.Assert(ok)
+	return ppg
+}
@@ -0,0 +1,38 @@
+package page
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTagSlug(t *testing.T) {
+	if err := testTagSlug(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testTagSlug() error {
+	for tag, slug := range map[string]string{
+		"Hello World": "hello-world",
+		" Café ":      "cafe",
+		"a/b":         "a-b",
+		"C++":         "c",
+		"Go 1.21":     "go-1-21",
+	} {
+		if s := TagSlug(tag); s != slug {
+			return fmt.Errorf("%q: expected %q, got %q", tag, slug, s)
+		}
+	}
+	for _, tag := range []string{"日本", "Go 日本"} {
+		s := TagSlug(tag)
+		for _, r := range s {
+			if !('a' <= r && r <= 'z' || '0' <= r && r <= '9' || r == '-') {
+				return fmt.Errorf("%q: slug %q is not plain", tag, s)
+			}
+		}
+		if s == "" || s == "go" || s == TagSlug("日本語") {
+			return fmt.Errorf("%q: slug %q not kept apart", tag, s)
+		}
+	}
+	return nil
+}
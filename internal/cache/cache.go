@@ -0,0 +1,132 @@
+// Package cache stores the results of expensive rendering on disk under keys
+// derived from their inputs, so that unchanged pages needn't be rendered again.
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/hash"
+)
+
+// version is part of every key, so that changes to how pages are rendered
+// invalidate what was cached before them.
+const version = "1"
+
+// A Cache is a directory of results. A nil Cache stores nothing.
+type Cache struct {
+	dir string
+
+	// used holds the keys looked up or stored since the Cache was opened
+	mu   *sync.Mutex
+	used map[string]bool
+}
+
+// Open uses dir as a cache, creating it if necessary.
+func Open(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, fmt.Errorf("cannot make dir: %w", err)
+	}
+	return &Cache{dir, &sync.Mutex{}, map[string]bool{}}, nil
+}
+
+// Key derives a key from the inputs to a result.
+func Key(inputs ...string) string {
+	return hash.Combine(append([]string{version}, inputs...)...)
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// Get returns the result stored under key, if any.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.use(key)
+	b, err := os.ReadFile(c.path(key))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("warning: cannot read cache: %v\n", err)
+		}
+		return nil, false
+	}
+	return b, true
+}
+
+// Put stores b under key. The file is written aside and renamed into place, so
+// that concurrent readers never see a partial result.
+func (c *Cache) Put(key string, b []byte) error {
+	if c == nil {
+		return nil
+	}
+	c.use(key)
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return fmt.Errorf("cannot make dir: %w", err)
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "tmp-")
+	if err != nil {
+		return fmt.Errorf("cannot create file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot close: %w", err)
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Do returns the result stored under key, computing it with f and storing it
+// if there is none. Failing to store the result is not an error.
+func (c *Cache) Do(key string, f func() ([]byte, error)) ([]byte, error) {
+	if b, ok := c.Get(key); ok {
+		return b, nil
+	}
+	b, err := f()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Put(key, b); err != nil {
+		log.Printf("warning: cannot write cache: %v\n", err)
+	}
+	return b, nil
+}
+
+func (c *Cache) use(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.used[key] = true
+}
+
+// Prune removes the results that have been neither looked up nor stored since
+// the Cache was opened, so that it holds only what the last build used.
+func (c *Cache) Prune() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return filepath.WalkDir(c.dir, func(
+		path string, d fs.DirEntry, err error,
+	) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() || strings.HasPrefix(name, "tmp-") || c.used[name] {
+			return nil
+		}
+		return os.Remove(path)
+	})
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	if err := testDo(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testDo() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	c, err := Open(dir)
+	if err != nil {
+		return err
+	}
+	calls := 0
+	render := func() ([]byte, error) {
+		calls++
+		return []byte("rendered"), nil
+	}
+	for i := 0; i < 2; i++ {
+		b, err := c.Do(Key("page", "content"), render)
+		if err != nil {
+			return err
+		}
+		if string(b) != "rendered" {
+			return fmt.Errorf("unexpected result %q", b)
+		}
+	}
+	if calls != 1 {
+		return fmt.Errorf("rendered %d times", calls)
+	}
+	if _, err := c.Do(Key("page", "changed"), render); err != nil {
+		return err
+	}
+	if calls != 2 {
+		return fmt.Errorf("changed content not rendered")
+	}
+	return nil
+}
+
+func TestPrune(t *testing.T) {
+	if err := testPrune(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testPrune() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	c, err := Open(dir)
+	if err != nil {
+		return err
+	}
+	for _, key := range []string{Key("old"), Key("kept")} {
+		if err := c.Put(key, []byte(key)); err != nil {
+			return err
+		}
+	}
+	if c, err = Open(dir); err != nil {
+		return err
+	}
+	if _, ok := c.Get(Key("kept")); !ok {
+		return fmt.Errorf("kept not found")
+	}
+	if err := c.Put(Key("new"), []byte("new")); err != nil {
+		return err
+	}
+	if err := c.Prune(); err != nil {
+		return fmt.Errorf("cannot prune: %w", err)
+	}
+	if c, err = Open(dir); err != nil {
+		return err
+	}
+	for key, kept := range map[string]bool{
+		"old": false, "kept": true, "new": true,
+	} {
+		if _, ok := c.Get(Key(key)); ok != kept {
+			return fmt.Errorf("%s: expected kept %v, got %v", key, kept, ok)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/sitefile"
+	"gopkg.in/yaml.v3"
+)
+
+// File is the name of the configuration file at the root of a source
+// directory.
+const File = "hyloblog.yaml"
+
+const (
+	DefaultChromaStyle = "based"
+	DefaultDateFormat  = "Jan 02, 2006"
+)
+
+type Config struct {
+	BaseURL     string                 `yaml:"baseurl"`
+	Title       string                 `yaml:"title"`
+	Theme       string                 `yaml:"theme"`
+	ChromaStyle string                 `yaml:"chroma"`
+	DateFormat  string                 `yaml:"dateformat"`
+	Head        string                 `yaml:"head"`
+	Foot        string                 `yaml:"foot"`
+	Robots      string                 `yaml:"robots"`
+	Workers     int                    `yaml:"workers"`
+	Cache       string                 `yaml:"cache"`
+	Gitignore   bool                   `yaml:"gitignore"`
+	PathPrefix  string                 `yaml:"pathprefix"`
+	CustomPages map[string]*CustomPage `yaml:"pages"`
+}
+
+type CustomPage struct {
+	Tmpl   string            `yaml:"template"`
+	Values map[string]string `yaml:"data"`
+}
+
+func (pg *CustomPage) Template() string        { return pg.Tmpl }
+func (pg *CustomPage) Data() map[string]string { return pg.Values }
+
+// Load reads the configuration file at the root of src. If there is no such
+// file the defaults are returned. A relative theme directory is resolved
+// against src.
+func Load(src string) (*Config, error) {
+	return LoadFS(os.DirFS(src), src)
+}
+
+// LoadFS is like Load but reads the configuration file at the root of fsys,
+// resolving a relative theme directory against dir.
+func LoadFS(fsys fs.FS, dir string) (*Config, error) {
+	var c Config
+	b, err := fs.ReadFile(fsys, File)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("cannot read file: %w", err)
+		}
+	} else if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal: %w", err)
+	}
+	if c.Theme != "" && !filepath.IsAbs(c.Theme) {
+		c.Theme = filepath.Join(dir, c.Theme)
+	}
+	if c.Cache != "" && !filepath.IsAbs(c.Cache) {
+		c.Cache = filepath.Join(dir, c.Cache)
+	}
+	for url, pg := range c.CustomPages {
+		if pg == nil || pg.Tmpl == "" {
+			return nil, fmt.Errorf("page %q has no template", url)
+		}
+	}
+	return c.withdefaults(), nil
+}
+
+func (c *Config) withdefaults() *Config {
+	if c.ChromaStyle == "" {
+		c.ChromaStyle = DefaultChromaStyle
+	}
+	if c.DateFormat == "" {
+		c.DateFormat = DefaultDateFormat
+	}
+	return c
+}
+
+// Override returns a copy of c in which every field set in o replaces the
+// corresponding one in c. Custom pages are merged, with those in o winning.
+func (c *Config) Override(o *Config) *Config {
+	n := *c
+	n.BaseURL = override(c.BaseURL, o.BaseURL)
+	n.Title = override(c.Title, o.Title)
+	n.Theme = override(c.Theme, o.Theme)
+	n.ChromaStyle = override(c.ChromaStyle, o.ChromaStyle)
+	n.DateFormat = override(c.DateFormat, o.DateFormat)
+	n.Head = override(c.Head, o.Head)
+	n.Foot = override(c.Foot, o.Foot)
+	n.Robots = override(c.Robots, o.Robots)
+	n.Workers = override(c.Workers, o.Workers)
+	n.Cache = override(c.Cache, o.Cache)
+	n.Gitignore = override(c.Gitignore, o.Gitignore)
+	n.PathPrefix = override(c.PathPrefix, o.PathPrefix)
+	n.CustomPages = map[string]*CustomPage{}
+	for url, pg := range c.CustomPages {
+		n.CustomPages[url] = pg
+	}
+	for url, pg := range o.CustomPages {
+		n.CustomPages[url] = pg
+	}
+	return &n
+}
+
+func override[T comparable](old, new T) T {
+	var zero T
+	if new != zero {
+		return new
+	}
+	return old
+}
+
+// Pages returns the custom pages in the form expected for injection into an
+// area.
+func (c *Config) Pages() map[string]sitefile.CustomPage {
+	m := map[string]sitefile.CustomPage{}
+	for url, pg := range c.CustomPages {
+		m[url] = pg
+	}
+	return m
+}
@@ -0,0 +1,116 @@
+package gitindex
+
+import (
+	"net/mail"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const coauthorTrailer = "co-authored-by:"
+
+// coauthors returns the people credited in Co-authored-by trailers of msg.
+func coauthors(msg string) []Person {
+	var people []Person
+	for _, line := range trailers(msg) {
+		if len(line) < len(coauthorTrailer) ||
+			!strings.EqualFold(line[:len(coauthorTrailer)], coauthorTrailer) {
+			continue
+		}
+		addr, err := mail.ParseAddress(line[len(coauthorTrailer):])
+		if err != nil {
+			continue
+		}
+		people = append(people, Person{addr.Name, addr.Address})
+	}
+	return people
+}
+
+// trailers returns the lines of the trailer block of msg: its last paragraph,
+// if that follows the subject and each of its lines is a "Key: value" trailer
+// or a continuation of one.
+func trailers(msg string) []string {
+	paras := strings.Split(
+		strings.TrimSpace(strings.ReplaceAll(msg, "\r\n", "\n")), "\n\n",
+	)
+	if len(paras) < 2 {
+		return nil
+	}
+	lines := strings.Split(paras[len(paras)-1], "\n")
+	for i, line := range lines {
+		if i > 0 && (strings.HasPrefix(line, " ") ||
+			strings.HasPrefix(line, "\t")) {
+			continue
+		}
+		if !istrailer(line) {
+			return nil
+		}
+	}
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return lines
+}
+
+func istrailer(line string) bool {
+	key, _, ok := strings.Cut(line, ":")
+	if !ok || key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// Authors returns the distinct people who contributed to the file at path,
+// both as commit authors and through Co-authored-by trailers, in order of
+// first contribution by author time. Identities are resolved through the
+// repository's .mailmap, if there is one.
+func (idx *Index) Authors(path string) ([]Person, error) {
+	commits, err := idx.Commits(path)
+	if err != nil {
+		return nil, err
+	}
+	byauthortime := make([]Commit, len(commits))
+	for i, c := range commits {
+		byauthortime[len(commits)-1-i] = c
+	}
+	sort.SliceStable(byauthortime, func(i, j int) bool {
+		return byauthortime[i].Author.When.Before(
+			byauthortime[j].Author.When,
+		)
+	})
+	var people []Person
+	seen := map[string]bool{}
+	for _, c := range byauthortime {
+		for _, p := range idx.CommitAuthors(c) {
+			if key := p.key(); !seen[key] {
+				seen[key] = true
+				people = append(people, p)
+			}
+		}
+	}
+	return people, nil
+}
+
+// CommitAuthors returns the author and co-authors of c, resolved through the
+// .mailmap.
+func (idx *Index) CommitAuthors(c Commit) []Person {
+	people := append(
+		[]Person{{c.Author.Name, c.Author.Email}}, c.CoAuthors...,
+	)
+	for i := range people {
+		people[i] = idx.mailmap.resolve(people[i])
+	}
+	return people
+}
+
+func (p Person) key() string {
+	if p.Email != "" {
+		return strings.ToLower(p.Email)
+	}
+	return p.Name
+}
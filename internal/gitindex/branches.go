@@ -0,0 +1,46 @@
+package gitindex
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+type Branch struct {
+	Name string
+	Head Commit
+}
+
+// Branches returns the local branches of the repository at path, which may be
+// bare, in order of name.
+func Branches(path string) ([]Branch, error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open repo: %w", err)
+	}
+	iter, err := repo.Branches()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list branches: %w", err)
+	}
+	var branches []Branch
+	if err := iter.ForEach(func(ref *plumbing.Reference) error {
+		c, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return fmt.Errorf(
+				"cannot get head of %q: %w", ref.Name().Short(), err,
+			)
+		}
+		branches = append(
+			branches, Branch{ref.Name().Short(), tocommit(c, 0)},
+		)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(branches, func(i, j int) bool {
+		return branches[i].Name < branches[j].Name
+	})
+	return branches, nil
+}
@@ -0,0 +1,71 @@
+package gitindex
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Diff returns the unified diff of the change c made to the file at c.Path,
+// relative to its first parent. Only that file is compared, under its previous
+// path if c renamed it.
+func (idx *Index) Diff(c Commit) (string, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	commit, err := idx.repo.CommitObject(plumbing.NewHash(c.Hash))
+	if err != nil {
+		return "", fmt.Errorf("cannot get commit: %w", err)
+	}
+	to, err := changeentry(commit, c.Path)
+	if err != nil {
+		return "", err
+	}
+	var from object.ChangeEntry
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return "", fmt.Errorf("cannot get parent: %w", err)
+		}
+		if from, err = changeentry(parent, idx.parentpath(c)); err != nil {
+			return "", fmt.Errorf("parent: %w", err)
+		}
+	}
+	if from.Name == to.Name && from.TreeEntry.Hash == to.TreeEntry.Hash {
+		return "", nil
+	}
+	patch, err := (&object.Change{From: from, To: to}).Patch()
+	if err != nil {
+		return "", fmt.Errorf("cannot get patch: %w", err)
+	}
+	return patch.String(), nil
+}
+
+// changeentry returns the entry of the file at path in the tree of c, which is
+// empty if there is no such file.
+func changeentry(c *object.Commit, path string) (object.ChangeEntry, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return object.ChangeEntry{}, fmt.Errorf("cannot get tree: %w", err)
+	}
+	e, err := tree.FindEntry(path)
+	if err != nil {
+		if errors.Is(err, object.ErrEntryNotFound) ||
+			errors.Is(err, object.ErrDirectoryNotFound) {
+			return object.ChangeEntry{}, nil
+		}
+		return object.ChangeEntry{}, fmt.Errorf("cannot find entry: %w", err)
+	}
+	return object.ChangeEntry{Name: path, Tree: tree, TreeEntry: *e}, nil
+}
+
+// parentpath is the path in its first parent of the file c changed.
+func (idx *Index) parentpath(c Commit) string {
+	for _, r := range idx.renames[c.Path] {
+		if r.seq == c.seq {
+			return r.from
+		}
+	}
+	return c.Path
+}
@@ -0,0 +1,333 @@
+package gitindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// An Index records, for every path in the history of a repository, the
+// commits that touched it. It is built by walking the commit graph once so
+// that looking up a file is cheap.
+type Index struct {
+	// mu serialises reads from the repository's object store after the
+	// index is built
+	mu      *sync.Mutex
+	repo    *git.Repository
+	root    string
+	head    *object.Commit
+	files   map[string][]Commit
+	renames map[string][]rename
+	mailmap *mailmap
+}
+
+type Commit struct {
+	Hash      string
+	Author    Signature
+	Committer Signature
+	CoAuthors []Person
+	Message   string
+
+	// Path is the slash-separated path of the file at the commit, which
+	// differs from the one looked up if the file has since been renamed.
+	Path string
+
+	// seq is the position of the commit in the walk from HEAD, which
+	// visits commits newest first by commit time
+	seq int
+}
+
+// Subject is the first line of the commit message.
+func (c Commit) Subject() string {
+	msg := strings.TrimSpace(c.Message)
+	if i := strings.IndexByte(msg, '\n'); i != -1 {
+		return msg[:i]
+	}
+	return msg
+}
+
+type Signature struct {
+	Name, Email string
+	When        time.Time
+}
+
+// A rename records that the commit at seq moved the file at from to the path
+// under which the rename is stored.
+type rename struct {
+	from string
+	seq  int
+}
+
+// Build indexes the history reachable from HEAD of the repository whose
+// working tree is at root. A repository without commits has an empty index.
+func Build(root string) (*Index, error) {
+	repo, err := git.PlainOpen(root)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open repo: %w", err)
+	}
+	mm, err := readmailmap(filepath.Join(root, ".mailmap"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read mailmap: %w", err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return newindex(repo, root, nil, mm), nil
+		}
+		return nil, fmt.Errorf("cannot get head: %w", err)
+	}
+	c, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("cannot get head commit: %w", err)
+	}
+	idx := newindex(repo, root, c, mm)
+	if err := idx.walk(c); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// BuildRef indexes the history reachable from ref, which may be a branch, tag
+// or commit hash, in the repository at path. The repository may be bare, and
+// the .mailmap is read from the tree at ref. Files are looked up by their paths
+// in that tree joined to path.
+func BuildRef(path, ref string) (*Index, error) {
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open repo: %w", err)
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve %q: %w", ref, err)
+	}
+	c, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get commit: %w", err)
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get tree: %w", err)
+	}
+	mm, err := treemailmap(tree)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read mailmap: %w", err)
+	}
+	idx := newindex(repo, path, c, mm)
+	if err := idx.walk(c); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func newindex(
+	repo *git.Repository, root string, head *object.Commit, mm *mailmap,
+) *Index {
+	return &Index{
+		&sync.Mutex{}, repo, root, head,
+		map[string][]Commit{}, map[string][]rename{}, mm,
+	}
+}
+
+// Head returns the hash of the commit the index was built from, which is empty
+// for a repository without commits.
+func (idx *Index) Head() string {
+	if idx.head == nil {
+		return ""
+	}
+	return idx.head.Hash.String()
+}
+
+// FS returns the tree of the commit the index was built from.
+func (idx *Index) FS() (fs.FS, error) {
+	if idx.head == nil {
+		return nil, fmt.Errorf("no commits")
+	}
+	tree, err := idx.head.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get tree: %w", err)
+	}
+	return &treefs{tree, idx.mu}, nil
+}
+
+// walk indexes the commits reachable from head in order of commit time, so that
+// the commits on either side of a merge are interleaved as they were made.
+func (idx *Index) walk(head *object.Commit) error {
+	iter := object.NewCommitIterCTime(head, nil, nil)
+	defer iter.Close()
+	seq := 0
+	if err := iter.ForEach(func(c *object.Commit) error {
+		ch, err := changedpaths(c)
+		if err != nil {
+			return fmt.Errorf("commit %s: %w", c.Hash, err)
+		}
+		commit := tocommit(c, seq)
+		for path := range ch.paths {
+			commit.Path = path
+			idx.files[path] = append(idx.files[path], commit)
+		}
+		for to, from := range ch.renames {
+			idx.renames[to] = append(
+				idx.renames[to], rename{from, seq},
+			)
+		}
+		seq++
+		return nil
+	}); err != nil {
+		return fmt.Errorf("cannot walk history: %w", err)
+	}
+	return nil
+}
+
+func tocommit(c *object.Commit, seq int) Commit {
+	return Commit{
+		Hash: c.Hash.String(),
+		Author: Signature{
+			Name:  c.Author.Name,
+			Email: c.Author.Email,
+			When:  c.Author.When,
+		},
+		Committer: Signature{
+			Name:  c.Committer.Name,
+			Email: c.Committer.Email,
+			When:  c.Committer.When,
+		},
+		CoAuthors: coauthors(c.Message),
+		Message:   c.Message,
+		seq:       seq,
+	}
+}
+
+type changes struct {
+	paths map[string]bool
+
+	// renames maps destination paths to their sources
+	renames map[string]string
+}
+
+// changedpaths returns the paths that c changes. For a merge these are the
+// paths that differ from every parent, i.e. those changed by the merge itself
+// rather than brought in from one side.
+func changedpaths(c *object.Commit) (*changes, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get tree: %w", err)
+	}
+	if c.NumParents() == 0 {
+		paths, err := treepaths(tree)
+		if err != nil {
+			return nil, err
+		}
+		return &changes{paths, map[string]string{}}, nil
+	}
+	var ch *changes
+	if err := c.Parents().ForEach(func(p *object.Commit) error {
+		ptree, err := p.Tree()
+		if err != nil {
+			return fmt.Errorf("cannot get parent tree: %w", err)
+		}
+		diff, err := object.DiffTreeWithOptions(
+			context.Background(),
+			ptree, tree,
+			object.DefaultDiffTreeOptions,
+		)
+		if err != nil {
+			return fmt.Errorf("cannot diff: %w", err)
+		}
+		pch := tochanges(diff)
+		if ch == nil {
+			ch = pch
+			return nil
+		}
+		ch.intersect(pch)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+func treepaths(tree *object.Tree) (map[string]bool, error) {
+	paths := map[string]bool{}
+	if err := tree.Files().ForEach(func(f *object.File) error {
+		paths[f.Name] = true
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("cannot list files: %w", err)
+	}
+	return paths, nil
+}
+
+func tochanges(diff object.Changes) *changes {
+	ch := &changes{map[string]bool{}, map[string]string{}}
+	for _, c := range diff {
+		from, to := c.From.Name, c.To.Name
+		if from != "" {
+			ch.paths[from] = true
+		}
+		if to != "" {
+			ch.paths[to] = true
+		}
+		if from != "" && to != "" && from != to {
+			ch.renames[to] = from
+		}
+	}
+	return ch
+}
+
+func (ch *changes) intersect(o *changes) {
+	for path := range ch.paths {
+		if !o.paths[path] {
+			delete(ch.paths, path)
+		}
+	}
+	for to, from := range ch.renames {
+		if o.renames[to] != from {
+			delete(ch.renames, to)
+		}
+	}
+}
+
+// Commits returns the commits that touched the file at path in the order of
+// the walk from HEAD, so that the most recent comes first. Like git log
+// --follow, history from before the file was renamed into path is included.
+func (idx *Index) Commits(path string) ([]Commit, error) {
+	rel, err := filepath.Rel(idx.root, path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get relative path: %w", err)
+	}
+	return idx.follow(filepath.ToSlash(rel), -1), nil
+}
+
+// follow returns the commits on path older than the one at seq after. When the
+// file was renamed into path, the commits up to the rename are followed by
+// those on its previous path. Each step moves strictly further back in the
+// walk, so a file moved back and forth is followed without looping.
+func (idx *Index) follow(path string, after int) []Commit {
+	until, from := math.MaxInt, ""
+	for _, r := range idx.renames[path] {
+		if r.seq > after {
+			until, from = r.seq, r.from
+			break
+		}
+	}
+	var commits []Commit
+	for _, c := range idx.files[path] {
+		if c.seq > after && c.seq <= until {
+			commits = append(commits, c)
+		}
+	}
+	if from != "" {
+		commits = append(commits, idx.follow(from, until)...)
+	}
+	return commits
+}
@@ -0,0 +1,594 @@
+package gitindex
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestIndex(t *testing.T) {
+	if err := testIndex(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testIndex() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	r, err := newrepo(dir)
+	if err != nil {
+		return err
+	}
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, step := range []struct {
+		file, content, author string
+	}{
+		{"a.md", "a", "Ann"},
+		{"b.md", "b", "Bob"},
+		{"a.md", "aa", "Cat"},
+	} {
+		if err := r.commit(
+			step.file, step.content, step.author,
+			day.AddDate(0, 0, i),
+		); err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+	}
+
+	idx, err := Build(dir)
+	if err != nil {
+		return fmt.Errorf("cannot build: %w", err)
+	}
+	commits, err := idx.Commits(filepath.Join(dir, "a.md"))
+	if err != nil {
+		return err
+	}
+	if len(commits) != 2 {
+		return fmt.Errorf("a.md: expected 2 commits, got %d", len(commits))
+	}
+	if name := commits[0].Author.Name; name != "Cat" {
+		return fmt.Errorf("a.md: latest author %q", name)
+	}
+	if when := commits[1].Author.When; !when.Equal(day) {
+		return fmt.Errorf("a.md: first commit at %s", when)
+	}
+	commits, err = idx.Commits(filepath.Join(dir, "b.md"))
+	if err != nil {
+		return err
+	}
+	if len(commits) != 1 || commits[0].Author.Name != "Bob" {
+		return fmt.Errorf("b.md: unexpected commits %+v", commits)
+	}
+	return nil
+}
+
+func TestRename(t *testing.T) {
+	if err := testRename(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testRename() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	r, err := newrepo(dir)
+	if err != nil {
+		return err
+	}
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	const content = "a post long enough to be recognised after a move\n"
+	if err := r.commit("old/post.md", content, "Ann", day); err != nil {
+		return err
+	}
+	if err := r.move(
+		"old/post.md", "new/post.md", "Bob", day.AddDate(0, 1, 0),
+	); err != nil {
+		return err
+	}
+
+	idx, err := Build(dir)
+	if err != nil {
+		return fmt.Errorf("cannot build: %w", err)
+	}
+	commits, err := idx.Commits(filepath.Join(dir, "new/post.md"))
+	if err != nil {
+		return err
+	}
+	if len(commits) != 2 {
+		return fmt.Errorf("expected 2 commits, got %d", len(commits))
+	}
+	if first := commits[1]; first.Author.Name != "Ann" ||
+		!first.Author.When.Equal(day) {
+		return fmt.Errorf("rename not followed: %+v", first)
+	}
+	return nil
+}
+
+func TestMerge(t *testing.T) {
+	if err := testMerge(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testMerge() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := mergehistory(dir); err != nil {
+		return err
+	}
+
+	idx, err := Build(dir)
+	if err != nil {
+		return fmt.Errorf("cannot build: %w", err)
+	}
+	commits, err := idx.Commits(filepath.Join(dir, "post.md"))
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, c := range commits {
+		names = append(names, c.Author.Name)
+	}
+	if fmt.Sprint(names) != "[Bob Ann]" {
+		return fmt.Errorf("expected [Bob Ann], got %v", names)
+	}
+	return nil
+}
+
+// mergehistory makes dir a repository in which Ann writes post.md, Cat then
+// edits other.md on master, Bob edits post.md on a side branch after that,
+// and the side branch is merged into master.
+func mergehistory(dir string) error {
+	r, err := newrepo(dir)
+	if err != nil {
+		return err
+	}
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := r.commit("post.md", "a", "Ann", day); err != nil {
+		return err
+	}
+	root, err := r.head()
+	if err != nil {
+		return err
+	}
+	if err := r.commit(
+		"other.md", "b", "Cat", day.AddDate(0, 0, 1),
+	); err != nil {
+		return err
+	}
+	master, err := r.head()
+	if err != nil {
+		return err
+	}
+	if err := r.wt.Checkout(&git.CheckoutOptions{
+		Hash:   root,
+		Branch: plumbing.NewBranchReferenceName("side"),
+		Create: true,
+	}); err != nil {
+		return fmt.Errorf("cannot checkout side: %w", err)
+	}
+	if err := r.commit(
+		"post.md", "c", "Bob", day.AddDate(0, 0, 2),
+	); err != nil {
+		return err
+	}
+	side, err := r.head()
+	if err != nil {
+		return err
+	}
+	if err := r.wt.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.Master,
+	}); err != nil {
+		return fmt.Errorf("cannot checkout master: %w", err)
+	}
+	if err := os.WriteFile(
+		filepath.Join(dir, "post.md"), []byte("c"), 0666,
+	); err != nil {
+		return err
+	}
+	if _, err := r.wt.Add("post.md"); err != nil {
+		return err
+	}
+	_, err = r.wt.Commit("merge", &git.CommitOptions{
+		Author: &object.Signature{
+			Name: "Ann", Email: "Ann@example.com",
+			When: day.AddDate(0, 0, 3),
+		},
+		Parents: []plumbing.Hash{master, side},
+	})
+	return err
+}
+
+func TestDiff(t *testing.T) {
+	if err := testDiff(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testDiff() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	r, err := newrepo(dir)
+	if err != nil {
+		return err
+	}
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := r.commit("a.md", "one\n", "Ann", day); err != nil {
+		return err
+	}
+	if err := r.commit("a.md", "one\ntwo\n", "Ann", day); err != nil {
+		return err
+	}
+	if err := r.move("a.md", "b/a.md", "Ann", day); err != nil {
+		return err
+	}
+
+	idx, err := Build(dir)
+	if err != nil {
+		return fmt.Errorf("cannot build: %w", err)
+	}
+	commits, err := idx.Commits(filepath.Join(dir, "b/a.md"))
+	if err != nil {
+		return err
+	}
+	if len(commits) != 3 {
+		return fmt.Errorf("expected 3 commits, got %d", len(commits))
+	}
+	// the move is compared with the file at its old path
+	if diff, err := idx.Diff(commits[0]); err != nil {
+		return fmt.Errorf("move: %w", err)
+	} else if strings.Contains(diff, "+one\n") {
+		return fmt.Errorf("move: diff has content:\n%s", diff)
+	}
+	for i, want := range []string{"+two\n", "+one\n"} {
+		diff, err := idx.Diff(commits[i+1])
+		if err != nil {
+			return fmt.Errorf("commit %d: %w", i, err)
+		}
+		if !strings.Contains(diff, want) {
+			return fmt.Errorf("commit %d: diff lacks %q:\n%s", i, want, diff)
+		}
+	}
+	return nil
+}
+
+func TestAuthors(t *testing.T) {
+	if err := testAuthors(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testAuthors() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	r, err := newrepo(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(
+		filepath.Join(dir, ".mailmap"),
+		[]byte("Ann Smith <ann@example.com> <Ann@example.com>\n"),
+		0666,
+	); err != nil {
+		return err
+	}
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := r.commit("post.md", "a", "Bob", day); err != nil {
+		return err
+	}
+	if err := r.commit(
+		"post.md", "b",
+		"Ann", day.AddDate(0, 0, 1),
+	); err != nil {
+		return err
+	}
+	if err := os.WriteFile(
+		filepath.Join(dir, "post.md"), []byte("c"), 0666,
+	); err != nil {
+		return err
+	}
+	if _, err := r.wt.Add("post.md"); err != nil {
+		return err
+	}
+	if err := r.save(
+		"edit\n\nCo-authored-by: Cat <cat@example.com>\n",
+		"Bob", day.AddDate(0, 0, 2),
+	); err != nil {
+		return err
+	}
+
+	idx, err := Build(dir)
+	if err != nil {
+		return fmt.Errorf("cannot build: %w", err)
+	}
+	authors, err := idx.Authors(filepath.Join(dir, "post.md"))
+	if err != nil {
+		return err
+	}
+	expected := []Person{
+		{"Bob", "Bob@example.com"},
+		{"Ann Smith", "ann@example.com"},
+		{"Cat", "cat@example.com"},
+	}
+	if fmt.Sprint(authors) != fmt.Sprint(expected) {
+		return fmt.Errorf("expected %v, got %v", expected, authors)
+	}
+	return nil
+}
+
+func TestMergeAuthors(t *testing.T) {
+	if err := testMergeAuthors(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testMergeAuthors() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := mergehistory(dir); err != nil {
+		return err
+	}
+
+	idx, err := Build(dir)
+	if err != nil {
+		return fmt.Errorf("cannot build: %w", err)
+	}
+	authors, err := idx.Authors(filepath.Join(dir, "post.md"))
+	if err != nil {
+		return err
+	}
+	expected := []Person{
+		{"Ann", "Ann@example.com"},
+		{"Bob", "Bob@example.com"},
+	}
+	if fmt.Sprint(authors) != fmt.Sprint(expected) {
+		return fmt.Errorf("expected %v, got %v", expected, authors)
+	}
+	return nil
+}
+
+func TestAuthorTime(t *testing.T) {
+	if err := testAuthorTime(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testAuthorTime checks that a change written first but committed later, as
+// when it is rebased, still comes first.
+func testAuthorTime() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	r, err := newrepo(dir)
+	if err != nil {
+		return err
+	}
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := r.commit(
+		"post.md", "a", "Ann", day.AddDate(0, 0, 1),
+	); err != nil {
+		return err
+	}
+	if err := os.WriteFile(
+		filepath.Join(dir, "post.md"), []byte("b"), 0666,
+	); err != nil {
+		return err
+	}
+	if _, err := r.wt.Add("post.md"); err != nil {
+		return err
+	}
+	if _, err := r.wt.Commit("rebased", &git.CommitOptions{
+		Author: &object.Signature{
+			Name: "Bob", Email: "Bob@example.com", When: day,
+		},
+		Committer: &object.Signature{
+			Name: "Ann", Email: "Ann@example.com",
+			When: day.AddDate(0, 0, 2),
+		},
+	}); err != nil {
+		return err
+	}
+
+	idx, err := Build(dir)
+	if err != nil {
+		return fmt.Errorf("cannot build: %w", err)
+	}
+	authors, err := idx.Authors(filepath.Join(dir, "post.md"))
+	if err != nil {
+		return err
+	}
+	expected := []Person{
+		{"Bob", "Bob@example.com"},
+		{"Ann", "Ann@example.com"},
+	}
+	if fmt.Sprint(authors) != fmt.Sprint(expected) {
+		return fmt.Errorf("expected %v, got %v", expected, authors)
+	}
+	return nil
+}
+
+func TestCoauthors(t *testing.T) {
+	if err := testCoauthors(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testCoauthors() error {
+	cat := []Person{{"Cat", "cat@example.com"}}
+	for _, c := range []struct {
+		msg      string
+		expected []Person
+	}{
+		{"edit\n\nCo-authored-by: Cat <cat@example.com>\n", cat},
+		{
+			"edit\n\nSigned-off-by: Bob <bob@example.com>\n" +
+				"co-authored-by: Cat <cat@example.com>",
+			cat,
+		},
+		{"Co-authored-by: Cat <cat@example.com>", nil},
+		{
+			"edit\n\nCo-authored-by: Cat <cat@example.com>\n\n" +
+				"More about the edit.",
+			nil,
+		},
+		{
+			"edit\n\nThanks to\nCo-authored-by: Cat <cat@example.com>",
+			nil,
+		},
+	} {
+		if got := coauthors(c.msg); fmt.Sprint(got) != fmt.Sprint(c.expected) {
+			return fmt.Errorf(
+				"%q: expected %v, got %v", c.msg, c.expected, got,
+			)
+		}
+	}
+	return nil
+}
+
+func TestBuildRef(t *testing.T) {
+	if err := testBuildRef(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testBuildRef() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	r, err := newrepo(dir)
+	if err != nil {
+		return err
+	}
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := r.commit("sub/a.md", "one", "Ann", day); err != nil {
+		return err
+	}
+	if err := r.commit("b.md", "two", "Bob", day); err != nil {
+		return err
+	}
+
+	idx, err := BuildRef(dir, "HEAD~1")
+	if err != nil {
+		return fmt.Errorf("cannot build: %w", err)
+	}
+	commits, err := idx.Commits(filepath.Join(dir, "b.md"))
+	if err != nil {
+		return err
+	}
+	if len(commits) != 0 {
+		return fmt.Errorf("b.md: unreachable commits %+v", commits)
+	}
+	fsys, err := idx.FS()
+	if err != nil {
+		return err
+	}
+	if err := fstest.TestFS(fsys, "sub/a.md"); err != nil {
+		return err
+	}
+	if b, err := fs.ReadFile(fsys, "sub/a.md"); err != nil {
+		return err
+	} else if string(b) != "one" {
+		return fmt.Errorf("sub/a.md: unexpected content %q", b)
+	}
+	if _, err := fs.Stat(fsys, "b.md"); !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("b.md: expected not to exist, got %v", err)
+	}
+	return nil
+}
+
+type repo struct {
+	dir string
+	wt  *git.Worktree
+}
+
+func newrepo(dir string) (*repo, error) {
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot init: %w", err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get worktree: %w", err)
+	}
+	return &repo{dir, wt}, nil
+}
+
+func (r *repo) commit(file, content, author string, when time.Time) error {
+	path := filepath.Join(r.dir, file)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+		return err
+	}
+	if _, err := r.wt.Add(file); err != nil {
+		return fmt.Errorf("cannot add: %w", err)
+	}
+	return r.save(file, author, when)
+}
+
+func (r *repo) move(from, to, author string, when time.Time) error {
+	if err := os.MkdirAll(
+		filepath.Dir(filepath.Join(r.dir, to)), 0777,
+	); err != nil {
+		return err
+	}
+	if _, err := r.wt.Move(from, to); err != nil {
+		return fmt.Errorf("cannot move: %w", err)
+	}
+	return r.save(to, author, when)
+}
+
+// head returns the hash of the commit checked out.
+func (r *repo) head() (plumbing.Hash, error) {
+	gr, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("cannot open: %w", err)
+	}
+	ref, err := gr.Head()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("cannot get head: %w", err)
+	}
+	return ref.Hash(), nil
+}
+
+func (r *repo) save(msg, author string, when time.Time) error {
+	_, err := r.wt.Commit(msg, &git.CommitOptions{
+		Author: &object.Signature{
+			Name: author, Email: author + "@example.com", When: when,
+		},
+	})
+	return err
+}
@@ -0,0 +1,133 @@
+package gitindex
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type Person struct {
+	Name, Email string
+}
+
+// A mailmap canonicalises author identities as described in gitmailmap(5).
+type mailmap struct {
+	byemail     map[string]Person
+	bynameemail map[string]Person
+}
+
+func newmailmap() *mailmap {
+	return &mailmap{map[string]Person{}, map[string]Person{}}
+}
+
+func readmailmap(path string) (*mailmap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return newmailmap(), nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return parsemailmap(f)
+}
+
+func treemailmap(tree *object.Tree) (*mailmap, error) {
+	f, err := tree.File(".mailmap")
+	if err != nil {
+		if errors.Is(err, object.ErrFileNotFound) {
+			return newmailmap(), nil
+		}
+		return nil, err
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return parsemailmap(r)
+}
+
+func parsemailmap(r io.Reader) (*mailmap, error) {
+	m := newmailmap()
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if err := m.addline(line); err != nil {
+			return nil, fmt.Errorf("%q: %w", line, err)
+		}
+	}
+	return m, s.Err()
+}
+
+// addline handles the forms
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+func (m *mailmap) addline(line string) error {
+	var people []Person
+	for {
+		open := strings.IndexByte(line, '<')
+		if open == -1 {
+			break
+		}
+		end := strings.IndexByte(line[open:], '>')
+		if end == -1 {
+			return fmt.Errorf("unclosed email")
+		}
+		people = append(people, Person{
+			strings.TrimSpace(line[:open]),
+			line[open+1 : open+end],
+		})
+		line = line[open+end+1:]
+	}
+	switch len(people) {
+	case 1:
+		m.add(people[0], people[0])
+	case 2:
+		m.add(people[0], people[1])
+	default:
+		return fmt.Errorf("expected one or two emails")
+	}
+	return nil
+}
+
+func (m *mailmap) add(proper, commit Person) {
+	key := strings.ToLower(commit.Email)
+	if commit.Name != "" {
+		m.bynameemail[commit.Name+"\x00"+key] = proper
+		return
+	}
+	m.byemail[key] = proper
+}
+
+func (m *mailmap) resolve(p Person) Person {
+	key := strings.ToLower(p.Email)
+	proper, ok := m.bynameemail[p.Name+"\x00"+key]
+	if !ok {
+		proper, ok = m.byemail[key]
+	}
+	if !ok {
+		return p
+	}
+	if proper.Name != "" {
+		p.Name = proper.Name
+	}
+	if proper.Email != "" {
+		p.Email = proper.Email
+	}
+	return p
+}
@@ -0,0 +1,224 @@
+package gitindex
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// A treefs is a read-only fs.FS over a git tree. Submodules are left out since
+// their contents are not in the repository. Reads from the object store are
+// serialised by mu, and files are read whole when opened.
+type treefs struct {
+	tree *object.Tree
+	mu   *sync.Mutex
+}
+
+func (t *treefs) Open(name string) (fs.File, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info, err := t.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := t.readdir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &treedir{treefile{info, nil}, entries}, nil
+	}
+	b, err := readall(info.file)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &treefile{info, io.NopCloser(bytes.NewReader(b))}, nil
+}
+
+func readall(f *object.File) ([]byte, error) {
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (t *treefs) Stat(name string) (fs.FileInfo, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stat("stat", name)
+}
+
+func (t *treefs) ReadFile(name string) ([]byte, error) {
+	f, err := t.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func (t *treefs) ReadDir(name string) ([]fs.DirEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.readdir(name)
+}
+
+func (t *treefs) readdir(name string) ([]fs.DirEntry, error) {
+	tree, err := t.subtree("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	var entries []fs.DirEntry
+	for _, e := range tree.Entries {
+		if e.Mode == filemode.Submodule {
+			continue
+		}
+		info, err := entryinfo(tree, e)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		entries = append(entries, info)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+func (t *treefs) subtree(op, name string) (*object.Tree, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return t.tree, nil
+	}
+	tree, err := t.tree.Tree(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: notexist(err)}
+	}
+	return tree, nil
+}
+
+func (t *treefs) stat(op, name string) (*treeinfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return &treeinfo{name: ".", mode: fs.ModeDir | 0755}, nil
+	}
+	parent, err := t.subtree(op, path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	e, err := parent.FindEntry(path.Base(name))
+	if err != nil || e.Mode == filemode.Submodule {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	info, err := entryinfo(parent, *e)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return info, nil
+}
+
+func notexist(err error) error {
+	if errors.Is(err, object.ErrDirectoryNotFound) ||
+		errors.Is(err, object.ErrEntryNotFound) {
+		return fs.ErrNotExist
+	}
+	return err
+}
+
+func entryinfo(tree *object.Tree, e object.TreeEntry) (*treeinfo, error) {
+	mode, err := e.Mode.ToOSFileMode()
+	if err != nil {
+		return nil, err
+	}
+	info := &treeinfo{name: e.Name, mode: mode}
+	if e.Mode == filemode.Dir {
+		return info, nil
+	}
+	f, err := tree.TreeEntryFile(&e)
+	if err != nil {
+		return nil, err
+	}
+	info.file = f
+	return info, nil
+}
+
+// A treeinfo describes a tree entry both as an fs.FileInfo and an fs.DirEntry.
+// Git records no modification times.
+type treeinfo struct {
+	name string
+	mode fs.FileMode
+	file *object.File
+}
+
+func (i *treeinfo) Name() string       { return i.name }
+func (i *treeinfo) Mode() fs.FileMode  { return i.mode }
+func (i *treeinfo) ModTime() time.Time { return time.Time{} }
+func (i *treeinfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *treeinfo) Sys() any           { return nil }
+
+func (i *treeinfo) Size() int64 {
+	if i.file == nil {
+		return 0
+	}
+	return i.file.Size
+}
+
+func (i *treeinfo) Type() fs.FileMode          { return i.mode.Type() }
+func (i *treeinfo) Info() (fs.FileInfo, error) { return i, nil }
+
+type treefile struct {
+	info *treeinfo
+	r    io.ReadCloser
+}
+
+func (f *treefile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *treefile) Read(b []byte) (int, error) {
+	if f.r == nil {
+		return 0, &fs.PathError{
+			Op: "read", Path: f.info.name, Err: errors.New("is a directory"),
+		}
+	}
+	return f.r.Read(b)
+}
+
+func (f *treefile) Close() error {
+	if f.r == nil {
+		return nil
+	}
+	return f.r.Close()
+}
+
+type treedir struct {
+	treefile
+	entries []fs.DirEntry
+}
+
+func (d *treedir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,80 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Files hashes the files at paths, which must lie within root. Each file
+// contributes its path relative to root and its content, in sorted order, so
+// the result does not depend on modification times, ownership or the order
+// of paths.
+func Files(root string, paths []string) (string, error) {
+	rels := make([]string, len(paths))
+	for i, path := range paths {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return "", fmt.Errorf("cannot get relative path: %w", err)
+		}
+		rels[i] = filepath.ToSlash(rel)
+	}
+	sort.Strings(rels)
+	h := sha256.New()
+	for _, rel := range rels {
+		sum, err := File(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			return "", fmt.Errorf("cannot hash %q: %w", rel, err)
+		}
+		fmt.Fprintf(h, "%s\x00%s\n", rel, sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Dir hashes every regular file under root as by Files.
+func Dir(root string) (string, error) {
+	var paths []string
+	if err := filepath.WalkDir(
+		root,
+		func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				paths = append(paths, path)
+			}
+			return nil
+		},
+	); err != nil {
+		return "", fmt.Errorf("cannot walk: %w", err)
+	}
+	return Files(root, paths)
+}
+
+// Combine derives a single hash from several.
+func Combine(hashes ...string) string {
+	h := sha256.New()
+	for _, s := range hashes {
+		fmt.Fprintf(h, "%s\n", s)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// File hashes the content of the file at path.
+func File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,74 @@
+package hash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDir(t *testing.T) {
+	if err := testDir(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testDir() error {
+	a, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(a)
+	b, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(b)
+
+	for _, dir := range []string{a, b} {
+		if err := os.MkdirAll(filepath.Join(dir, "sub"), 0777); err != nil {
+			return err
+		}
+		for name, content := range map[string]string{
+			"index.md":     "# index",
+			"sub/post.md":  "# post",
+			"sub/logo.png": "png",
+		} {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+				return err
+			}
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(b, "index.md"), old, old); err != nil {
+		return err
+	}
+
+	ha, err := Dir(a)
+	if err != nil {
+		return fmt.Errorf("cannot hash a: %w", err)
+	}
+	hb, err := Dir(b)
+	if err != nil {
+		return fmt.Errorf("cannot hash b: %w", err)
+	}
+	if ha != hb {
+		return fmt.Errorf("equivalent dirs differ: %s != %s", ha, hb)
+	}
+
+	if err := os.WriteFile(
+		filepath.Join(b, "sub/post.md"), []byte("# changed"), 0666,
+	); err != nil {
+		return err
+	}
+	hb, err = Dir(b)
+	if err != nil {
+		return fmt.Errorf("cannot rehash b: %w", err)
+	}
+	if ha == hb {
+		return fmt.Errorf("different dirs have same hash %s", ha)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+// BasicAuth lets through to h only the requests carrying user and password.
+func BasicAuth(h http.Handler, user, password string) http.Handler {
+	wantuser, wantpass := sha256.Sum256([]byte(user)),
+		sha256.Sum256([]byte(password))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		// comparing digests keeps the time taken independent of length
+		gotuser, gotpass := sha256.Sum256([]byte(u)),
+			sha256.Sum256([]byte(p))
+		if !ok || subtle.ConstantTimeCompare(gotuser[:], wantuser[:])&
+			subtle.ConstantTimeCompare(gotpass[:], wantpass[:]) != 1 {
+			w.Header().Set(
+				"WWW-Authenticate", `Basic realm="hyloblog", charset="UTF-8"`,
+			)
+			http.Error(
+				w, http.StatusText(http.StatusUnauthorized),
+				http.StatusUnauthorized,
+			)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
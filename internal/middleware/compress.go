@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// encodings are the supported content codings in order of preference.
+var encodings = []string{"br", "gzip"}
+
+// Compress compresses the textual responses of h with brotli or gzip,
+// whichever the client prefers of those it accepts. The coding is appended to
+// the ETag, so that each encoded representation has its own.
+func Compress(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		enc := negotiate(r.Header.Get("Accept-Encoding"))
+		if enc == "" || r.Method == http.MethodHead ||
+			r.Header.Get("Range") != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if inm := r.Header.Get("If-None-Match"); inm != "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("If-None-Match", stripcoding(inm, enc))
+		}
+		cw := &compresswriter{ResponseWriter: w, enc: enc}
+		defer cw.Close()
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// negotiate returns the preferred coding acceptable according to the
+// Accept-Encoding header, or the empty string if there is none.
+func negotiate(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := strings.ReplaceAll(params, " ", "")
+		accepted[strings.ToLower(name)] = q != "q=0" && q != "q=0.0"
+	}
+	for _, enc := range encodings {
+		if accepted[enc] {
+			return enc
+		}
+	}
+	return ""
+}
+
+func stripcoding(inm, enc string) string {
+	return strings.ReplaceAll(inm, "-"+enc+`"`, `"`)
+}
+
+func compressible(contenttype string) bool {
+	t, _, err := mime.ParseMediaType(contenttype)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(t, "text/"):
+		return t != "text/event-stream"
+	case strings.HasSuffix(t, "+xml"), strings.HasSuffix(t, "/xml"),
+		strings.HasSuffix(t, "/json"), strings.HasSuffix(t, "+json"),
+		t == "application/javascript":
+		return true
+	default:
+		return false
+	}
+}
+
+// A compresswriter decides whether to compress a response when its header is
+// written.
+type compresswriter struct {
+	http.ResponseWriter
+	enc     string
+	decided bool
+	zw      io.WriteCloser
+}
+
+func (cw *compresswriter) WriteHeader(status int) {
+	if !cw.decided {
+		cw.decided = true
+		hdr := cw.Header()
+		if status != http.StatusNoContent &&
+			status != http.StatusNotModified &&
+			status != http.StatusPartialContent &&
+			hdr.Get("Content-Encoding") == "" &&
+			compressible(hdr.Get("Content-Type")) {
+			cw.zw = newencoder(cw.enc, cw.ResponseWriter)
+			hdr.Set("Content-Encoding", cw.enc)
+			hdr.Del("Content-Length")
+		}
+		if etag := hdr.Get("ETag"); etag != "" &&
+			(cw.zw != nil || status == http.StatusNotModified) &&
+			strings.HasSuffix(etag, `"`) {
+			hdr.Set("ETag", etag[:len(etag)-1]+"-"+cw.enc+`"`)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compresswriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.zw != nil {
+		return cw.zw.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush sends what has been compressed so far.
+func (cw *compresswriter) Flush() {
+	if !cw.decided {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := cw.zw.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compresswriter) Close() error {
+	if cw.zw == nil {
+		return nil
+	}
+	return cw.zw.Close()
+}
+
+func (cw *compresswriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func newencoder(enc string, w io.Writer) io.WriteCloser {
+	if enc == "br" {
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	}
+	return gzip.NewWriter(w)
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	if err := testCompress(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testCompress() error {
+	page := strings.Repeat("<p>hello</p>\n", 100)
+	h := Compress(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"abc"`)
+			if r.Header.Get("If-None-Match") == `"abc"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, page)
+		},
+	))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "br;q=0, gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if enc := w.Header().Get("Content-Encoding"); enc != "gzip" {
+		return fmt.Errorf("expected gzip, got %q", enc)
+	}
+	etag := w.Header().Get("ETag")
+	if etag != `"abc-gzip"` {
+		return fmt.Errorf("unexpected etag %q", etag)
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		return err
+	}
+	if b, err := io.ReadAll(zr); err != nil {
+		return err
+	} else if string(b) != page {
+		return fmt.Errorf("body changed by compression")
+	}
+
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified {
+		return fmt.Errorf("revalidation: expected 304, got %d", w.Code)
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != page {
+		return fmt.Errorf("compressed without Accept-Encoding")
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// Package middleware wraps the handlers of a served site for production use.
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// Log logs each request served by h once it is done.
+func Log(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		log.Printf(
+			"%s %s %d %dB %s\n", r.Method, r.URL, rec.status,
+			rec.size, time.Since(start).Round(time.Microsecond),
+		)
+	})
+}
+
+// A recorder notes the status and size of a response.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(p)
+	rec.size += n
+	return n, err
+}
+
+func (rec *recorder) Unwrap() http.ResponseWriter { return rec.ResponseWriter }
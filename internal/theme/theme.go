@@ -4,18 +4,32 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"os"
 	"path/filepath"
 	"text/template"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/hash"
 )
 
 type Theme struct {
-	index, def *template.Template
-	dir        string
+	index, def        *template.Template
+	tags, tag         *template.Template
+	history, revision *template.Template
+	notfound, failed  *template.Template
+	dir               string
 }
 
 const (
 	themeIndex   = "index.html"
 	themeDefault = "_default.html"
+	themeTags    = "tags.html"
+	themeTag     = "tag.html"
+	themeHistory = "history.html"
+	themeRev     = "revision.html"
+
+	themeNotFound    = "404.html"
+	themeServerError = "500.html"
 )
 
 func ParseTheme(dir string) (*Theme, error) {
@@ -27,11 +41,53 @@ func ParseTheme(dir string) (*Theme, error) {
 	if err != nil {
 		return nil, fmt.Errorf("cannot get default: %w", err)
 	}
-	return &Theme{index, def, dir}, nil
+	tags, err := parseoptional(filepath.Join(dir, themeTags))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get tags: %w", err)
+	}
+	tag, err := parseoptional(filepath.Join(dir, themeTag))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get tag: %w", err)
+	}
+	history, err := parseoptional(filepath.Join(dir, themeHistory))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get history: %w", err)
+	}
+	revision, err := parseoptional(filepath.Join(dir, themeRev))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get revision: %w", err)
+	}
+	notfound, err := parseoptional(filepath.Join(dir, themeNotFound))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get not found: %w", err)
+	}
+	failed, err := parseoptional(filepath.Join(dir, themeServerError))
+	if err != nil {
+		return nil, fmt.Errorf("cannot get server error: %w", err)
+	}
+	return &Theme{
+		index, def, tags, tag, history, revision, notfound, failed, dir,
+	}, nil
+}
+
+// Hash identifies the content of the theme in dir.
+func Hash(dir string) (string, error) {
+	return hash.Dir(dir)
+}
+
+func parseoptional(path string) (*template.Template, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return template.ParseFiles(path)
 }
 
 type IndexData struct {
 	Title, Content string
+	SiteTitle      string
 	Posts          []Post
 	Head, Foot     string
 }
@@ -43,10 +99,16 @@ func (thm *Theme) ExecuteIndex(w io.Writer, data *IndexData) error {
 type Post struct {
 	Title, Link, Category, Date string
 	Authors                     []Author
+	Tags                        []Tag
+	Draft                       bool
 }
 
 type Author struct {
-	Name, Page string
+	Name, Page, Email string
+}
+
+type Tag struct {
+	Name, Link string
 }
 
 type DefaultData struct {
@@ -54,6 +116,9 @@ type DefaultData struct {
 	SiteTitle      string
 	Date           string
 	Authors        []Author
+	Tags           []Tag
+	Draft          bool
+	HistoryLink    string
 	Head, Foot     string
 }
 
@@ -61,6 +126,126 @@ func (thm *Theme) ExecuteDefault(w io.Writer, data *DefaultData) error {
 	return thm.def.Execute(w, data)
 }
 
+var ErrNoTagTemplate = errors.New("no tag template")
+
+// HasTags reports whether the theme provides the templates needed to generate
+// the tag listing pages.
+func (thm *Theme) HasTags() bool {
+	return thm.tags != nil && thm.tag != nil
+}
+
+type TagCount struct {
+	Tag
+	Count int
+}
+
+type TagsData struct {
+	Title, SiteTitle string
+	Tags             []TagCount
+	Head, Foot       string
+}
+
+func (thm *Theme) ExecuteTags(w io.Writer, data *TagsData) error {
+	if thm.tags == nil {
+		return fmt.Errorf("%w: %s", ErrNoTagTemplate, themeTags)
+	}
+	return thm.tags.Execute(w, data)
+}
+
+type TagData struct {
+	Title, SiteTitle string
+	Tag              Tag
+	Posts            []Post
+	Head, Foot       string
+}
+
+func (thm *Theme) ExecuteTag(w io.Writer, data *TagData) error {
+	if thm.tag == nil {
+		return fmt.Errorf("%w: %s", ErrNoTagTemplate, themeTag)
+	}
+	return thm.tag.Execute(w, data)
+}
+
+var ErrNoHistoryTemplate = errors.New("no history template")
+
+// HasHistory reports whether the theme provides the templates needed to
+// generate the revision history of posts.
+func (thm *Theme) HasHistory() bool {
+	return thm.history != nil && thm.revision != nil
+}
+
+type Revision struct {
+	Hash, ShortHash string
+	Date            string
+	Subject         string
+	Message         string
+	Authors         []Author
+	Link            string
+}
+
+type HistoryData struct {
+	Title, SiteTitle string
+	PostLink         string
+	Revisions        []Revision
+	Head, Foot       string
+}
+
+func (thm *Theme) ExecuteHistory(w io.Writer, data *HistoryData) error {
+	if thm.history == nil {
+		return fmt.Errorf("%w: %s", ErrNoHistoryTemplate, themeHistory)
+	}
+	return thm.history.Execute(w, data)
+}
+
+type RevisionData struct {
+	Title, SiteTitle string
+	PostLink         string
+	HistoryLink      string
+	Revision         Revision
+	Diff             string
+	Head, Foot       string
+}
+
+func (thm *Theme) ExecuteRevision(w io.Writer, data *RevisionData) error {
+	if thm.revision == nil {
+		return fmt.Errorf("%w: %s", ErrNoHistoryTemplate, themeRev)
+	}
+	return thm.revision.Execute(w, data)
+}
+
+var ErrNoErrorTemplate = errors.New("no error page template")
+
+// HasErrorPage reports whether the theme provides a page for the HTTP status,
+// which is either 404 or 500.
+func (thm *Theme) HasErrorPage(status int) bool {
+	return thm.errortemplate(status) != nil
+}
+
+func (thm *Theme) errortemplate(status int) *template.Template {
+	switch status {
+	case http.StatusNotFound:
+		return thm.notfound
+	case http.StatusInternalServerError:
+		return thm.failed
+	default:
+		return nil
+	}
+}
+
+type ErrorData struct {
+	Title, SiteTitle string
+	Status           int
+	Head, Foot       string
+}
+
+func (thm *Theme) ExecuteErrorPage(w io.Writer, data *ErrorData) error {
+	tmpl := thm.errortemplate(data.Status)
+	if tmpl == nil {
+		return fmt.Errorf("%w: %d", ErrNoErrorTemplate, data.Status)
+	}
+	return tmpl.Execute(w, data)
+}
+
 var ErrNoCustomPageTemplate = errors.New("no custom page template")
 
 func (thm *Theme) ExecuteCustom(
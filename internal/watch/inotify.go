@@ -0,0 +1,129 @@
+//go:build linux
+
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const mask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_CLOSE_WRITE |
+	unix.IN_MODIFY | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_DELETE_SELF
+
+// A notifier watches directories with inotify. New subdirectories are watched
+// as they appear.
+type notifier struct {
+	f    *os.File
+	skip []string
+
+	mu   sync.Mutex
+	dirs map[int]string
+}
+
+func newnotifier(
+	dirs, skip []string, raw chan<- struct{},
+) (*notifier, error) {
+	dirs, err := abs(dirs)
+	if err != nil {
+		return nil, err
+	}
+	if skip, err = abs(skip); err != nil {
+		return nil, err
+	}
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("cannot init inotify: %w", err)
+	}
+	// a nonblocking file is read through the runtime poller, so that
+	// closing it ends a pending read
+	n := &notifier{
+		f:    os.NewFile(uintptr(fd), "inotify"),
+		skip: skip,
+		dirs: map[int]string{},
+	}
+	for _, dir := range dirs {
+		if err := walkdirs(dir, skip, n.add); err != nil {
+			n.close()
+			return nil, fmt.Errorf("cannot watch %q: %w", dir, err)
+		}
+	}
+	go n.read(raw)
+	return n, nil
+}
+
+func (n *notifier) add(dir string) error {
+	wd, err := unix.InotifyAddWatch(int(n.f.Fd()), dir, mask)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.dirs[wd] = dir
+	return nil
+}
+
+func (n *notifier) dir(wd int) (string, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	dir, ok := n.dirs[wd]
+	return dir, ok
+}
+
+func (n *notifier) read(raw chan<- struct{}) {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		k, err := n.f.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				fmt.Fprintf(os.Stderr, "watch: cannot read: %v\n", err)
+			}
+			return
+		}
+		for off := 0; off+unix.SizeofInotifyEvent <= k; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			start := off + unix.SizeofInotifyEvent
+			off = start + int(ev.Len)
+			name := strings.TrimRight(string(buf[start:off]), "\x00")
+			n.handle(ev, name, raw)
+		}
+	}
+}
+
+func (n *notifier) handle(
+	ev *unix.InotifyEvent, name string, raw chan<- struct{},
+) {
+	dir, ok := n.dir(int(ev.Wd))
+	if !ok {
+		return
+	}
+	if ev.Mask&unix.IN_IGNORED != 0 {
+		n.mu.Lock()
+		delete(n.dirs, int(ev.Wd))
+		n.mu.Unlock()
+		return
+	}
+	path := filepath.Join(dir, name)
+	if skipped(path, n.skip) {
+		return
+	}
+	if ev.Mask&unix.IN_ISDIR != 0 &&
+		ev.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 &&
+		!isgitdir(dir) {
+		if err := walkdirs(path, n.skip, n.add); err != nil {
+			fmt.Fprintf(os.Stderr, "watch: cannot add %q: %v\n", path, err)
+		}
+	}
+	signal(raw)
+}
+
+func (n *notifier) close() error {
+	return n.f.Close()
+}
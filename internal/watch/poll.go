@@ -0,0 +1,104 @@
+//go:build !linux
+
+package watch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const interval = 500 * time.Millisecond
+
+// A notifier compares the sizes and modification times of the files under its
+// directories at a fixed interval.
+type notifier struct {
+	done chan struct{}
+}
+
+func newnotifier(
+	dirs, skip []string, raw chan<- struct{},
+) (*notifier, error) {
+	dirs, err := abs(dirs)
+	if err != nil {
+		return nil, err
+	}
+	if skip, err = abs(skip); err != nil {
+		return nil, err
+	}
+	last, err := snapshot(dirs, skip)
+	if err != nil {
+		return nil, err
+	}
+	n := &notifier{make(chan struct{})}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+			case <-n.done:
+				return
+			}
+			s, err := snapshot(dirs, skip)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "watch: cannot scan: %v\n", err)
+				continue
+			}
+			if !equal(s, last) {
+				last = s
+				signal(raw)
+			}
+		}
+	}()
+	return n, nil
+}
+
+type stamp struct {
+	size    int64
+	modtime time.Time
+}
+
+func snapshot(dirs, skip []string) (map[string]stamp, error) {
+	s := map[string]stamp{}
+	for _, root := range dirs {
+		if err := walkdirs(root, skip, func(dir string) error {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				info, err := e.Info()
+				if err != nil {
+					continue
+				}
+				s[filepath.Join(dir, e.Name())] = stamp{
+					info.Size(), info.ModTime(),
+				}
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func equal(a, b map[string]stamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || !w.modtime.Equal(v.modtime) ||
+			w.size != v.size {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *notifier) close() error {
+	close(n.done)
+	return nil
+}
@@ -0,0 +1,119 @@
+// Package watch reports changes to the files under a set of directories.
+package watch
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// settle is how long the files must be left alone before a change is
+// reported, so that a burst of writes, e.g. from a checkout, yields one.
+const settle = 100 * time.Millisecond
+
+// A Watcher signals changes to the files under its directories.
+type Watcher struct {
+	n       *notifier
+	changes chan struct{}
+	done    chan struct{}
+}
+
+// New watches the trees rooted at dirs, leaving out those rooted at the paths
+// in skip. Only the top level of a .git directory is watched, which is enough
+// to notice commits and checkouts.
+func New(dirs, skip []string) (*Watcher, error) {
+	raw := make(chan struct{}, 1)
+	n, err := newnotifier(dirs, skip, raw)
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{n, make(chan struct{}, 1), make(chan struct{})}
+	go w.debounce(raw)
+	return w, nil
+}
+
+// Changes receives a value once files have changed.
+func (w *Watcher) Changes() <-chan struct{} { return w.changes }
+
+func (w *Watcher) Close() error {
+	close(w.done)
+	return w.n.close()
+}
+
+func (w *Watcher) debounce(raw <-chan struct{}) {
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-raw:
+			timer = time.After(settle)
+		case <-timer:
+			timer = nil
+			select {
+			case w.changes <- struct{}{}:
+			default:
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// signal sends on raw without blocking, since one pending value suffices.
+func signal(raw chan<- struct{}) {
+	select {
+	case raw <- struct{}{}:
+	default:
+	}
+}
+
+func skipped(path string, skip []string) bool {
+	for _, s := range skip {
+		if path == s || strings.HasPrefix(path, s+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkdirs calls f with every directory to be watched under root.
+func walkdirs(root string, skip []string, f func(dir string) error) error {
+	return filepath.WalkDir(root, func(
+		path string, d fs.DirEntry, err error,
+	) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if skipped(path, skip) {
+			return fs.SkipDir
+		}
+		if err := f(path); err != nil {
+			return err
+		}
+		if path != root && isgitdir(path) {
+			return fs.SkipDir
+		}
+		return nil
+	})
+}
+
+func isgitdir(path string) bool { return filepath.Base(path) == ".git" }
+
+func abs(paths []string) ([]string, error) {
+	var res []string
+	for _, p := range paths {
+		a, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	return res, nil
+}
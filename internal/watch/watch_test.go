@@ -0,0 +1,68 @@
+package watch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	if err := testWatch(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testWatch() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	skip := filepath.Join(dir, "out")
+	if err := os.Mkdir(skip, 0777); err != nil {
+		return err
+	}
+	w, err := New([]string{dir}, []string{skip})
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err := os.WriteFile(
+		filepath.Join(skip, "a.html"), []byte("a"), 0666,
+	); err != nil {
+		return err
+	}
+	select {
+	case <-w.Changes():
+		return fmt.Errorf("change reported in skipped directory")
+	case <-time.After(2 * time.Second):
+	}
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0777); err != nil {
+		return err
+	}
+	if err := wait(w); err != nil {
+		return fmt.Errorf("mkdir: %w", err)
+	}
+	if err := os.WriteFile(
+		filepath.Join(sub, "a.md"), []byte("a"), 0666,
+	); err != nil {
+		return err
+	}
+	if err := wait(w); err != nil {
+		return fmt.Errorf("write in new directory: %w", err)
+	}
+	return nil
+}
+
+func wait(w *Watcher) error {
+	select {
+	case <-w.Changes():
+		return nil
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("no change reported")
+	}
+}
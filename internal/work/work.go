@@ -0,0 +1,41 @@
+// Package work runs independent tasks on a bounded number of goroutines.
+package work
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Do runs f(0), ..., f(n-1) on at most workers goroutines, or on one per CPU
+// if workers is not positive. Every task is run even if some fail. The error
+// returned is that of the lowest-numbered failing task, so that it doesn't
+// depend on scheduling.
+func Do(n, workers int, f func(i int) error) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	workers = min(workers, n)
+	errs := make([]error, n)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				errs[i] = f(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package work
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDo(t *testing.T) {
+	if err := testDo(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testDo() error {
+	const n, workers = 100, 4
+	var running, peak, done atomic.Int32
+	err := Do(n, workers, func(i int) error {
+		r := running.Add(1)
+		for p := peak.Load(); r > p && !peak.CompareAndSwap(p, r); {
+			p = peak.Load()
+		}
+		defer running.Add(-1)
+		done.Add(1)
+		if i%10 == 3 {
+			return fmt.Errorf("task %d", i)
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "task 3" {
+		return fmt.Errorf("expected first error from task 3, got %v", err)
+	}
+	if done.Load() != n {
+		return fmt.Errorf("expected %d tasks run, got %d", n, done.Load())
+	}
+	if peak.Load() > workers {
+		return fmt.Errorf("%d tasks ran at once", peak.Load())
+	}
+	return nil
+}
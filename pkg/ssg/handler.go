@@ -7,6 +7,8 @@ import (
 
 	"github.com/hylodoc/hyloblog-ssg/internal/ast/area"
 	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/sitefile"
+	"github.com/hylodoc/hyloblog-ssg/internal/config"
+	"github.com/hylodoc/hyloblog-ssg/internal/hash"
 	"github.com/hylodoc/hyloblog-ssg/internal/theme"
 )
 
@@ -27,6 +29,9 @@ type Site interface {
 type site struct {
 	title, hash string
 	bindings    map[string]Resource
+
+	// prefix is the path prefix the site is generated for
+	prefix string
 }
 
 func (s *site) Title() string                 { return s.title }
@@ -37,40 +42,163 @@ var (
 	ErrTheme = errors.New("theme error")
 )
 
+// GenerateSiteWithBindings generates the site in src into target. Settings in
+// the configuration file at the root of src are used unless the corresponding
+// argument is non-empty, and custompages are added to those configured.
 func GenerateSiteWithBindings(
 	src, target, themeName, chromastyle string,
 	head, foot string,
 	custompages map[string]CustomPage,
 ) (Site, error) {
-	a, err := area.ParseArea(src, chromastyle)
+	cfg, err := config.Load(src)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load config: %w", err)
+	}
+	cfg = cfg.Override(&config.Config{
+		Theme:       themeName,
+		ChromaStyle: chromastyle,
+		Head:        head,
+		Foot:        foot,
+	})
+	a, err := area.ParseArea(src, cfg, false, target)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse area: %w", err)
+	}
+	return generate(a, cfg, target, custompages)
+}
+
+// GenerateSiteFromRef is like GenerateSiteWithBindings but reads the site from
+// the tree at ref, which may be a branch, tag or commit hash, of the git
+// repository at repo without checking it out. The repository may be bare.
+func GenerateSiteFromRef(
+	repo, ref, target, themeName, chromastyle string,
+	head, foot string,
+	custompages map[string]CustomPage,
+) (Site, error) {
+	r, err := area.OpenRef(repo, ref)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open ref: %w", err)
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load config: %w", err)
+	}
+	cfg = cfg.Override(&config.Config{
+		Theme:       themeName,
+		ChromaStyle: chromastyle,
+		Head:        head,
+		Foot:        foot,
+	})
+	a, err := r.Parse(cfg, false)
 	if err != nil {
 		return nil, fmt.Errorf("cannot parse area: %w", err)
 	}
-	if err := a.Inject(toinjectmap(custompages)); err != nil {
+	return generate(a, cfg, target, custompages)
+}
+
+func generate(
+	a *area.Area, cfg *config.Config, target string,
+	custompages map[string]CustomPage,
+) (Site, error) {
+	if err := a.Inject(
+		toinjectmap(cfg.Pages(), custompages),
+	); err != nil {
 		return nil, fmt.Errorf("injection error: %w", err)
 	}
-	bindings, err := a.GenerateWithBindings(target, themeName, head, foot)
+	bindings, err := a.GenerateWithBindings(target, cfg)
 	if err != nil {
 		if errors.Is(err, theme.ErrNoCustomPageTemplate) {
 			return nil, fmt.Errorf("%w: %w", ErrTheme, err)
 		}
 		return nil, fmt.Errorf("cannot generate: %w", err)
 	}
-	h, err := a.Hash()
+	h, err := sitehash(a, cfg.Theme)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get hash: %w", err)
 	}
-	return &site{gettitle(a), h, tofilemap(bindings)}, nil
+	return &site{
+		gettitle(a, cfg), h, tofilemap(bindings), cleanprefix(cfg.PathPrefix),
+	}, nil
 }
 
+// GetSiteHash returns the hash of the Site that would be generated from src
+// with the theme named in its configuration file (if any).
 func GetSiteHash(src string) (string, error) {
-	const defaultChromaStyle = "based"
+	return GetSiteHashWithOptions(src, HashOptions{})
+}
+
+// GetSiteHashFromRef returns the hash of the Site that GenerateSiteFromRef
+// would generate from ref with the theme named in its configuration file (if
+// any).
+func GetSiteHashFromRef(repo, ref string) (string, error) {
+	return GetSiteHashFromRefWithOptions(repo, ref, HashOptions{})
+}
+
+// HashOptions are the arguments to GenerateSiteWithBindings or
+// GenerateSiteFromRef that the hash of the Site depends on.
+type HashOptions struct {
+	// Theme overrides the theme named in the configuration file if
+	// non-empty.
+	Theme string
+
+	// Target is the directory the Site is generated into. It is left out of
+	// the source if it is inside it, and is ignored for refs.
+	Target string
+}
+
+// GetSiteHashWithOptions is like GetSiteHash but takes the arguments the site
+// is generated with in o.
+func GetSiteHashWithOptions(src string, o HashOptions) (string, error) {
+	cfg, err := config.Load(src)
+	if err != nil {
+		return "", fmt.Errorf("cannot load config: %w", err)
+	}
+	cfg = cfg.Override(&config.Config{Theme: o.Theme})
+	var skip []string
+	if o.Target != "" {
+		skip = append(skip, o.Target)
+	}
+	a, err := area.ParseArea(src, cfg, false, skip...)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse area: %w", err)
+	}
+	return sitehash(a, cfg.Theme)
+}
 
-	a, err := area.ParseArea(src, defaultChromaStyle)
+// GetSiteHashFromRefWithOptions is like GetSiteHashFromRef but takes the
+// arguments the site is generated with in o.
+func GetSiteHashFromRefWithOptions(
+	repo, ref string, o HashOptions,
+) (string, error) {
+	r, err := area.OpenRef(repo, ref)
+	if err != nil {
+		return "", fmt.Errorf("cannot open ref: %w", err)
+	}
+	cfg, err := r.Config()
+	if err != nil {
+		return "", fmt.Errorf("cannot load config: %w", err)
+	}
+	cfg = cfg.Override(&config.Config{Theme: o.Theme})
+	a, err := r.Parse(cfg, false)
 	if err != nil {
 		return "", fmt.Errorf("cannot parse area: %w", err)
 	}
-	return a.Hash()
+	return sitehash(a, cfg.Theme)
+}
+
+func sitehash(a *area.Area, themedir string) (string, error) {
+	srchash, err := a.Hash()
+	if err != nil {
+		return "", fmt.Errorf("source: %w", err)
+	}
+	if themedir == "" {
+		return srchash, nil
+	}
+	themehash, err := theme.Hash(themedir)
+	if err != nil {
+		return "", fmt.Errorf("theme: %w", err)
+	}
+	return hash.Combine(srchash, themehash), nil
 }
 
 // A Resource is any URL-accessible resource in a site.
@@ -84,6 +212,23 @@ type Resource interface {
 	// Post contains the Post-specific data of the Resource. If the Resource is not
 	// a post (i.e. !IsPost()) this will result in assertion failure.
 	Post() Post
+
+	// ContentType is the MIME type of the generated file.
+	ContentType() string
+
+	// Size is the length in bytes of the generated file.
+	Size() int64
+
+	// Hash is the hex-encoded SHA-256 of the generated file.
+	Hash() string
+
+	// ModTime is the last modification time of the generated file, which
+	// is kept across builds that leave the file unchanged.
+	ModTime() time.Time
+
+	// URL is the canonical URL of the Resource, which is absolute if the
+	// site has a base URL.
+	URL() string
 }
 
 type Post interface {
@@ -101,17 +246,44 @@ type Post interface {
 	// PlaintextPath is the path on disk to a file containing body of
 	// the post rendered in plaintext by Pandoc.
 	PlaintextPath() string
+
+	// Updated is the time the post was last changed, if available.
+	Updated() (time.Time, bool)
+
+	// Authors are the authors of the post, falling back to those of the
+	// site.
+	Authors() []Author
+
+	// Tags are the tags of the post.
+	Tags() []string
+
+	// Summary is the plain text of the first paragraph of the post, cut
+	// short if it is long.
+	Summary() string
+
+	// URL is the canonical URL of the post, which is absolute if the site
+	// has a base URL.
+	URL() string
+}
+
+// An Author is an author of a post. Email may be empty.
+type Author struct {
+	Name, Email string
 }
 
-func toinjectmap(m1 map[string]CustomPage) map[string]sitefile.CustomPage {
-	m2 := map[string]sitefile.CustomPage{}
+func toinjectmap(
+	m2 map[string]sitefile.CustomPage, m1 map[string]CustomPage,
+) map[string]sitefile.CustomPage {
 	for k, v := range m1 {
 		m2[k] = v
 	}
 	return m2
 }
 
-func gettitle(a *area.Area) string {
+func gettitle(a *area.Area, cfg *config.Config) string {
+	if cfg.Title != "" {
+		return cfg.Title
+	}
 	if s, err := a.Title(); err == nil {
 		return s
 	}
@@ -136,7 +308,26 @@ func newresource(rsc sitefile.Resource) Resource {
 
 func (r *resource) Path() string { return r.rsc.Path() }
 func (r *resource) IsPost() bool { return r.rsc.IsPost() }
-func (r *resource) Post() Post   { return r.rsc.Post() }
+func (r *resource) Post() Post   { return &post{r.rsc.Post()} }
+
+func (r *resource) ContentType() string { return r.rsc.ContentType() }
+func (r *resource) Size() int64         { return r.rsc.Size() }
+func (r *resource) Hash() string        { return r.rsc.Hash() }
+func (r *resource) ModTime() time.Time  { return r.rsc.ModTime() }
+func (r *resource) URL() string         { return r.rsc.URL() }
+
+type post struct {
+	sitefile.Post
+}
+
+func (p *post) Authors() []Author {
+	authors := p.Post.Authors()
+	res := make([]Author, len(authors))
+	for i, a := range authors {
+		res[i] = Author{a.Name, a.Email}
+	}
+	return res
+}
 
 // A CustomPage is a page generated without existing in the source directory.
 type CustomPage interface {
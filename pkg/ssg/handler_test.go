@@ -1,9 +1,21 @@
 package ssg
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
 	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 func TestHandler(t *testing.T) {
@@ -33,6 +45,16 @@ func testHandler() error {
 		return fmt.Errorf("cannot generate: %w", err)
 	}
 	fmt.Println("hash", s.Hash())
+	if h, err := GetSiteHashWithOptions(
+		"test", HashOptions{Theme: "../../theme/lit", Target: target},
+	); err != nil {
+		return fmt.Errorf("cannot get hash: %w", err)
+	} else if h != s.Hash() {
+		return fmt.Errorf("hash %q is not site hash %q", h, s.Hash())
+	}
+	if s.Title() != "Test Site" {
+		return fmt.Errorf("title %q not from config", s.Title())
+	}
 	bindings := s.Bindings()
 	for _, url := range []string{
 		"/",
@@ -42,6 +64,17 @@ func testHandler() error {
 		"/nest-no-ignore/post",
 		"/sub",
 		"/subok",
+		"/about",
+		"/tags",
+		"/tags/hello-world",
+		"/tags/code",
+		"/feed.xml",
+		"/atom.xml",
+		"/feed.json",
+		"/sitemap.xml",
+		"/robots.txt",
+		"/404",
+		"/500",
 	} {
 		if file, ok := bindings[url]; !ok {
 			return fmt.Errorf("%q not found", url)
@@ -51,6 +84,201 @@ func testHandler() error {
 			}
 		}
 	}
+	if _, ok := bindings["/draft"]; ok {
+		return fmt.Errorf("draft should not be bound")
+	}
+	if err := testmetadata(bindings["/abc/def"]); err != nil {
+		return fmt.Errorf("/abc/def: %w", err)
+	}
+	if err := testpost(bindings["/abc/def"].Post()); err != nil {
+		return fmt.Errorf("/abc/def: %w", err)
+	}
+	return testsitehandler(NewHandler(s))
+}
+
+func testpost(p Post) error {
+	if p.URL() != "https://example.com/abc/def" {
+		return fmt.Errorf("url %q", p.URL())
+	}
+	if tags := p.Tags(); len(tags) != 2 ||
+		tags[0] != "Hello World" || tags[1] != "code" {
+		return fmt.Errorf("tags %q", tags)
+	}
+	if p.Summary() != "one two three" {
+		return fmt.Errorf("summary %q", p.Summary())
+	}
+	return nil
+}
+
+func testmetadata(rsc Resource) error {
+	b, err := os.ReadFile(rsc.Path())
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(rsc.Path())
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(b)
+	if rsc.Hash() != hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("hash %q", rsc.Hash())
+	}
+	if rsc.Size() != int64(len(b)) {
+		return fmt.Errorf("size %d", rsc.Size())
+	}
+	if !rsc.ModTime().Equal(info.ModTime()) {
+		return fmt.Errorf("modtime %s", rsc.ModTime())
+	}
+	if rsc.ContentType() != "text/html; charset=utf-8" {
+		return fmt.Errorf("content type %q", rsc.ContentType())
+	}
+	if rsc.URL() != "https://example.com/abc/def" {
+		return fmt.Errorf("url %q", rsc.URL())
+	}
+	return nil
+}
+
+func testsitehandler(h http.Handler) error {
+	for _, tc := range []struct {
+		path, header, value string
+		status              int
+	}{
+		{"/abc/def", "Content-Type", "text/html; charset=utf-8", 200},
+		{"/feed.json", "Content-Type", "application/json", 200},
+		{"/abc/def/", "Location", "/abc/def", 301},
+		{"/nope", "Content-Type", "text/html; charset=utf-8", 404},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+		if w.Code != tc.status {
+			return fmt.Errorf(
+				"%s: expected %d, got %d", tc.path, tc.status, w.Code,
+			)
+		}
+		if v := w.Header().Get(tc.header); v != tc.value {
+			return fmt.Errorf("%s: %s is %q", tc.path, tc.header, v)
+		}
+	}
+	return nil
+}
+
+func TestSitemap(t *testing.T) {
+	if err := testSitemap(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testSitemap() error {
+	repo, err := os.MkdirTemp("", "")
+	if err != nil {
+		return fmt.Errorf("cannot make tempdir: %w", err)
+	}
+	defer os.RemoveAll(repo)
+	when := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := commitdir("test", repo, when); err != nil {
+		return fmt.Errorf("cannot commit: %w", err)
+	}
+	target, err := os.MkdirTemp("", "")
+	if err != nil {
+		return fmt.Errorf("cannot make tempdir: %w", err)
+	}
+	defer os.RemoveAll(target)
+	s, err := GenerateSiteWithBindings(
+		repo, target, "../../theme/lit", "algol_nu", "", "",
+		map[string]CustomPage{},
+	)
+	if err != nil {
+		return fmt.Errorf("cannot generate: %w", err)
+	}
+	b, err := os.ReadFile(s.Bindings()["/sitemap.xml"].Path())
+	if err != nil {
+		return err
+	}
+	var set struct {
+		URLs []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"url"`
+	}
+	if err := xml.Unmarshal(b, &set); err != nil {
+		return fmt.Errorf("cannot parse sitemap: %w", err)
+	}
+	lastmod := map[string]string{}
+	for _, u := range set.URLs {
+		if !strings.HasPrefix(u.Loc, "https://example.com/") {
+			return fmt.Errorf("loc %q not absolute", u.Loc)
+		}
+		lastmod[u.Loc] = u.LastMod
+	}
+	// the post has a custom url, which is used in dynamic mode
+	for _, loc := range []string{
+		"https://example.com/",
+		"https://example.com/abc/def",
+		"https://example.com/nest/post",
+	} {
+		mod, ok := lastmod[loc]
+		if !ok {
+			return fmt.Errorf("%q not in sitemap", loc)
+		}
+		t, err := time.Parse(time.RFC3339, mod)
+		if err != nil {
+			return fmt.Errorf("%q: cannot parse lastmod: %w", loc, err)
+		}
+		if !t.Equal(when) {
+			return fmt.Errorf("%q: lastmod %s is not %s", loc, t, when)
+		}
+	}
+	robots, err := readfile(s.Bindings()["/robots.txt"].Path())
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(
+		robots, "\nSitemap: https://example.com/sitemap.xml\n",
+	) {
+		return fmt.Errorf("robots.txt: %q", robots)
+	}
+	return nil
+}
+
+func TestHashTarget(t *testing.T) {
+	if err := testHashTarget(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testHashTarget() error {
+	src, err := os.MkdirTemp("", "")
+	if err != nil {
+		return fmt.Errorf("cannot make tempdir: %w", err)
+	}
+	defer os.RemoveAll(src)
+	if err := copydir("test", src); err != nil {
+		return fmt.Errorf("cannot copy: %w", err)
+	}
+	if err := os.WriteFile(
+		filepath.Join(src, "logo.png"), []byte("png"), 0666,
+	); err != nil {
+		return err
+	}
+	target := filepath.Join(src, "out")
+	for i := 0; i < 2; i++ {
+		s, err := GenerateSiteWithBindings(
+			src, target, "../../theme/lit", "", "", "",
+			map[string]CustomPage{},
+		)
+		if err != nil {
+			return fmt.Errorf("cannot generate: %w", err)
+		}
+		if h, err := GetSiteHashWithOptions(
+			src, HashOptions{Theme: "../../theme/lit", Target: target},
+		); err != nil {
+			return fmt.Errorf("cannot get hash: %w", err)
+		} else if h != s.Hash() {
+			return fmt.Errorf(
+				"build %d: hash %q is not site hash %q", i, h, s.Hash(),
+			)
+		}
+	}
 	return nil
 }
 
@@ -58,3 +286,103 @@ func readfile(file string) (string, error) {
 	b, err := os.ReadFile(file)
 	return string(b), err
 }
+
+func TestGenerateSiteFromRef(t *testing.T) {
+	if err := testGenerateSiteFromRef(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testGenerateSiteFromRef() error {
+	repo, err := os.MkdirTemp("", "")
+	if err != nil {
+		return fmt.Errorf("cannot make tempdir: %w", err)
+	}
+	defer os.RemoveAll(repo)
+	if err := commitdir("test", repo, time.Now()); err != nil {
+		return fmt.Errorf("cannot commit: %w", err)
+	}
+	// the tree is read from the object store, not the working tree
+	if err := os.Remove(filepath.Join(repo, "post.md")); err != nil {
+		return err
+	}
+	target, err := os.MkdirTemp("", "")
+	if err != nil {
+		return fmt.Errorf("cannot make tempdir: %w", err)
+	}
+	defer os.RemoveAll(target)
+	s, err := GenerateSiteFromRef(
+		repo, "HEAD", target,
+		"../../theme/lit", "algol_nu",
+		"HEADER", "FOOTER",
+		map[string]CustomPage{},
+	)
+	if err != nil {
+		return fmt.Errorf("cannot generate: %w", err)
+	}
+	if s.Title() != "Test Site" {
+		return fmt.Errorf("title %q not from config", s.Title())
+	}
+	if h, err := GetSiteHashFromRefWithOptions(
+		repo, "HEAD", HashOptions{Theme: "../../theme/lit"},
+	); err != nil {
+		return fmt.Errorf("cannot get hash: %w", err)
+	} else if h != s.Hash() {
+		return fmt.Errorf("hash %q is not site hash %q", h, s.Hash())
+	}
+	for _, url := range []string{"/", "/abc/def", "/nest/post", "/about"} {
+		if _, ok := s.Bindings()[url]; !ok {
+			return fmt.Errorf("%q not found", url)
+		}
+	}
+	return nil
+}
+
+// commitdir copies the files in src into a new git repository at dir and
+// commits them at when.
+func commitdir(src, dir string, when time.Time) error {
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		return fmt.Errorf("cannot init: %w", err)
+	}
+	if err := copydir(src, dir); err != nil {
+		return fmt.Errorf("cannot copy: %w", err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		return fmt.Errorf("cannot get worktree: %w", err)
+	}
+	if err := wt.AddGlob("."); err != nil {
+		return fmt.Errorf("cannot add: %w", err)
+	}
+	_, err = wt.Commit("init", &git.CommitOptions{
+		Author: &object.Signature{
+			Name: "Ann", Email: "ann@example.com", When: when,
+		},
+	})
+	return err
+}
+
+// copydir copies the files in src into dir.
+func copydir(src, dir string) error {
+	return filepath.WalkDir(src, func(
+		path string, d fs.DirEntry, err error,
+	) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+			return err
+		}
+		return os.WriteFile(dst, b, 0666)
+	})
+}
@@ -0,0 +1,126 @@
+package ssg
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A MultiHandler serves many sites, choosing one by the Host header of each
+// request and, optionally, a path prefix. Sites can be swapped while serving.
+type MultiHandler struct {
+	mu    sync.RWMutex
+	hosts map[string][]*mount
+}
+
+// A mount is a site served under a host and prefix.
+type mount struct {
+	prefix   string
+	site     Site
+	h        http.Handler
+	inflight sync.WaitGroup
+}
+
+func NewMultiHandler() *MultiHandler {
+	return &MultiHandler{hosts: map[string][]*mount{}}
+}
+
+// Set serves s for the requests to host whose paths lie under prefix, which
+// is empty to serve s at the root. A site served under a prefix must have been
+// generated with it as its path prefix. The new site serves every request from
+// the moment Set returns. The returned channel receives the site previously
+// served there, or nil, once no request is being served from it any more, so
+// that its files can be removed.
+func (m *MultiHandler) Set(host, prefix string, s Site) <-chan Site {
+	return m.swap(host, prefix, &mount{
+		prefix: cleanprefix(prefix),
+		site:   s,
+		h:      NewHandler(s),
+	})
+}
+
+// Remove stops serving the site under host and prefix, returning it as Set
+// does.
+func (m *MultiHandler) Remove(host, prefix string) <-chan Site {
+	return m.swap(host, prefix, nil)
+}
+
+func (m *MultiHandler) swap(host, prefix string, new *mount) <-chan Site {
+	host, prefix = canonicalhost(host), cleanprefix(prefix)
+	m.mu.Lock()
+	var old *mount
+	mounts := m.hosts[host][:0:0]
+	for _, mt := range m.hosts[host] {
+		if mt.prefix == prefix {
+			old = mt
+			continue
+		}
+		mounts = append(mounts, mt)
+	}
+	if new != nil {
+		mounts = append(mounts, new)
+	}
+	// the longest prefix is tried first
+	sort.Slice(mounts, func(i, j int) bool {
+		return len(mounts[i].prefix) > len(mounts[j].prefix)
+	})
+	if len(mounts) == 0 {
+		delete(m.hosts, host)
+	} else {
+		m.hosts[host] = mounts
+	}
+	m.mu.Unlock()
+	done := make(chan Site, 1)
+	if old == nil {
+		done <- nil
+		return done
+	}
+	go func() {
+		old.inflight.Wait()
+		done <- old.site
+	}()
+	return done
+}
+
+func (m *MultiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mt := m.match(canonicalhost(r.Host), r.URL.Path)
+	if mt == nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer mt.inflight.Done()
+	mt.h.ServeHTTP(w, r)
+}
+
+// match returns the mount serving path on host, counting the request as in
+// flight on it.
+func (m *MultiHandler) match(host, path string) *mount {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, mt := range m.hosts[host] {
+		if mt.prefix == "" || path == mt.prefix ||
+			strings.HasPrefix(path, mt.prefix+"/") {
+			mt.inflight.Add(1)
+			return mt
+		}
+	}
+	return nil
+}
+
+// canonicalhost lowercases host and removes any port.
+func canonicalhost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+func cleanprefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
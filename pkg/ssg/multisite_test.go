@@ -0,0 +1,184 @@
+package ssg
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hylodoc/hyloblog-ssg/internal/ast/area/sitefile"
+)
+
+func TestMultiHandler(t *testing.T) {
+	if err := testMultiHandler(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testMultiHandler() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	a, err := fakesite(dir, "a", "")
+	if err != nil {
+		return err
+	}
+	b, err := fakesite(dir, "b", "/docs")
+	if err != nil {
+		return err
+	}
+	c, err := fakesite(dir, "c", "/docs")
+	if err != nil {
+		return err
+	}
+
+	m := NewMultiHandler()
+	m.Set("a.example.com", "", a)
+	m.Set("a.example.com", "/docs/", b)
+	for _, tc := range []struct {
+		host, path string
+		status     int
+		body       string
+	}{
+		{"a.example.com", "/", 200, "a"},
+		{"A.example.com:8080", "/post", 200, "a post"},
+		{"a.example.com", "/docs", 200, "b"},
+		{"a.example.com", "/docs/post", 200, "b post"},
+		{"a.example.com", "/docsx", 404, ""},
+		{"b.example.com", "/", 404, ""},
+	} {
+		if err := expect(m, tc.host, tc.path, tc.status, tc.body); err != nil {
+			return err
+		}
+	}
+	r := httptest.NewRequest("GET", "/docs/post/?x=1", nil)
+	r.Host = "a.example.com"
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	if loc := w.Header().Get("Location"); loc != "/docs/post?x=1" {
+		return fmt.Errorf("redirected to %q", loc)
+	}
+	// a request still being served from b holds up its return, but not
+	// the swap
+	inflight := m.match("a.example.com", "/docs/post")
+	done := m.Set("a.example.com", "docs", c)
+	if err := expect(m, "a.example.com", "/docs", 200, "c"); err != nil {
+		return err
+	}
+	select {
+	case old := <-done:
+		return fmt.Errorf("swap returned %v while in flight", old)
+	default:
+	}
+	inflight.inflight.Done()
+	if old := <-done; old != b {
+		return fmt.Errorf("swap returned %v", old)
+	}
+	if old := <-m.Remove("a.example.com", ""); old != a {
+		return fmt.Errorf("remove returned %v", old)
+	}
+	if old := <-m.Remove("a.example.com", ""); old != nil {
+		return fmt.Errorf("second remove returned %v", old)
+	}
+	return expect(m, "a.example.com", "/", 404, "")
+}
+
+// fakesite makes a site named name, with an index and a post, in dir for the
+// path prefix.
+func fakesite(dir, name, prefix string) (Site, error) {
+	bindings := map[string]Resource{}
+	for _, f := range []struct{ url, file, content string }{
+		{"/", "index.html", name},
+		{"/post", "post.html", name + " post"},
+	} {
+		path := filepath.Join(dir, name+"-"+f.file)
+		if err := os.WriteFile(path, []byte(f.content), 0666); err != nil {
+			return nil, err
+		}
+		url := prefix + f.url
+		if prefix != "" && f.url == "/" {
+			url = prefix
+		}
+		bindings[url] = newresource(sitefile.NewNonPostResource(path))
+	}
+	return &site{name, name, bindings, prefix}, nil
+}
+
+func TestMultiHandlerPrefix(t *testing.T) {
+	if err := testMultiHandlerPrefix(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testMultiHandlerPrefix() error {
+	dir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	src, target := filepath.Join(dir, "src"), filepath.Join(dir, "out")
+	if err := copydir("test", src); err != nil {
+		return fmt.Errorf("cannot copy: %w", err)
+	}
+	f, err := os.OpenFile(
+		filepath.Join(src, "hyloblog.yaml"), os.O_APPEND|os.O_WRONLY, 0,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString("\npathprefix: docs\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	s, err := GenerateSiteWithBindings(
+		src, target, "../../theme/lit", "", "", "",
+		map[string]CustomPage{},
+	)
+	if err != nil {
+		return fmt.Errorf("cannot generate: %w", err)
+	}
+	m := NewMultiHandler()
+	m.Set("a.example.com", "/docs", s)
+	for _, c := range []struct {
+		path, want string
+		code       int
+	}{
+		{"/docs", `href="/docs/nest/post"`, http.StatusOK},
+		{"/docs/nest/post", "</html>", http.StatusOK},
+		{"/docs/missing", "<title>Not Found", http.StatusNotFound},
+		{"/nest/post", "", http.StatusNotFound},
+	} {
+		r := httptest.NewRequest("GET", c.path, nil)
+		r.Host = "a.example.com"
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != c.code || !strings.Contains(w.Body.String(), c.want) {
+			return fmt.Errorf(
+				"%s: status %d:\n%s", c.path, w.Code, w.Body,
+			)
+		}
+	}
+	return nil
+}
+
+func expect(h http.Handler, host, path string, status int, body string) error {
+	r := httptest.NewRequest("GET", path, nil)
+	r.Host = host
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != status {
+		return fmt.Errorf("%s%s: expected %d, got %d", host, path, status, w.Code)
+	}
+	if status == http.StatusOK && w.Body.String() != body {
+		return fmt.Errorf("%s%s: unexpected body %q", host, path, w.Body)
+	}
+	return nil
+}
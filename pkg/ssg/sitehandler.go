@@ -0,0 +1,98 @@
+package ssg
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// notFoundPath is where a site binds its theme's not found page, if the theme
+// has one, under the site's path prefix.
+const notFoundPath = "/404"
+
+// NewHandler serves the files bound in s, each with its content type and an
+// ETag derived from its hash. A path that differs from a bound one only by a trailing slash is
+// redirected to it, and unbound paths get the theme's not found page.
+func NewHandler(s Site) http.Handler {
+	bindings := s.Bindings()
+	notfoundpath := notFoundPath
+	if st, ok := s.(*site); ok {
+		notfoundpath = st.prefix + notFoundPath
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if path == "" {
+			path = "/"
+		}
+		if rsc, ok := bindings[path]; ok {
+			serveresource(w, r, rsc)
+			return
+		}
+		if alt := toggleslash(path); alt != "" {
+			if _, ok := bindings[alt]; ok {
+				http.Redirect(
+					w, r, toggledurl(r),
+					http.StatusMovedPermanently,
+				)
+				return
+			}
+		}
+		notfound(w, r, bindings[notfoundpath])
+	})
+}
+
+func serveresource(w http.ResponseWriter, r *http.Request, rsc Resource) {
+	t := rsc.ContentType()
+	if t == "" {
+		t = mime.TypeByExtension(filepath.Ext(rsc.Path()))
+	}
+	if t != "" {
+		w.Header().Set("Content-Type", t)
+	}
+	if sum := rsc.Hash(); sum != "" {
+		w.Header().Set("ETag", `"`+sum[:32]+`"`)
+	}
+	http.ServeFile(w, r, rsc.Path())
+}
+
+// toggleslash adds a trailing slash to path or removes it, returning the empty
+// string for the root.
+func toggleslash(path string) string {
+	if path == "/" {
+		return ""
+	}
+	if strings.HasSuffix(path, "/") {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path + "/"
+}
+
+// toggledurl is the URL of r with the trailing slash toggled. It is derived
+// from the URL as requested, which may have had a prefix stripped since.
+func toggledurl(r *http.Request) string {
+	u := *r.URL
+	if orig, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		u = *orig
+	}
+	u.Path, u.RawPath = toggleslash(u.Path), ""
+	return u.String()
+}
+
+// notfound responds with the not found page rsc, which may be nil.
+func notfound(w http.ResponseWriter, r *http.Request, rsc Resource) {
+	if rsc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	b, err := os.ReadFile(rsc.Path())
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(b)
+}